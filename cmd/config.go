@@ -30,7 +30,7 @@ import (
 // configCmd represents the config command
 var configCmd = &cobra.Command{
 	Use:   "config",
-	Short: "Set config for Privado CLI",
+	Short: "View and update the configuration for Privado CLI",
 }
 
 func init() {
@@ -25,16 +25,18 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/Privado-Inc/privado-cli/pkg/config"
+	"github.com/Privado-Inc/privado-cli/pkg/telemetry"
 	"github.com/spf13/cobra"
 )
 
 // configCmd represents the config command
 var metricsCmd = &cobra.Command{
 	Use:   "metrics",
-	Short: "List, enable, or disable telemetry for Privado CLI",
+	Short: "List, preview, enable, or disable telemetry for Privado CLI",
 	Run:   configMetrics,
 }
 
@@ -42,12 +44,19 @@ func configMetrics(cmd *cobra.Command, args []string) {
 	enableFlag, _ := cmd.Flags().GetBool("enable")
 	disableFlag, _ := cmd.Flags().GetBool("disable")
 
+	if listFlag, _ := cmd.Flags().GetBool("list"); listFlag {
+		listMetrics()
+	}
+	if previewFlag, _ := cmd.Flags().GetBool("preview"); previewFlag {
+		requestTelemetryPreview()
+	}
+
 	metricsEnabledTextMap := (map[bool]string{true: "enabled", false: "disabled"})
 
 	// if no flags are specified, show the current configuration
 	if !enableFlag && !disableFlag {
 		exit(fmt.Sprint(
-			fmt.Sprintf("Telemetry for Privado CLI: %s\n", strings.ToUpper(metricsEnabledTextMap[config.UserConfig.ConfigFile.MetricsEnabled])),
+			getMetricsStatusMessage(), "\n",
 			"You can use `--enable` or `--disable` flag to update telemetry preferences",
 		), false)
 	}
@@ -63,15 +72,61 @@ func configMetrics(cmd *cobra.Command, args []string) {
 		exit(fmt.Sprintf("Cannot save configuration file: %s", err), true)
 	}
 
-	exit(fmt.Sprintf("Telemetry for Privado CLI: %s", strings.ToUpper(metricsEnabledTextMap[config.UserConfig.ConfigFile.MetricsEnabled])), false)
+	if enabled, _ := config.GetMetricsEnabled(); enabled != config.UserConfig.ConfigFile.MetricsEnabled {
+		exit(fmt.Sprint(
+			fmt.Sprintf("Telemetry for Privado CLI is %s in the configuration file\n", metricsEnabledTextMap[config.UserConfig.ConfigFile.MetricsEnabled]),
+			getMetricsStatusMessage(),
+		), false)
+	}
+	exit(getMetricsStatusMessage(), false)
+}
+
+// the effective status, with its source (environment or configuration)
+func getMetricsStatusMessage() string {
+	enabled, source := config.GetMetricsEnabled()
+	return fmt.Sprintf("Telemetry for Privado CLI: %s (by %s)", strings.ToUpper(map[bool]string{true: "enabled", false: "disabled"}[enabled]), source)
+}
+
+func listMetrics() {
+	fmt.Println("> Telemetry events and metrics sent by Privado CLI:")
+	fmt.Println()
+	for _, metric := range telemetry.SupportedMetrics {
+		fmt.Printf("%-28s %s\n", metric.Key, metric.Description)
+	}
+	fmt.Println()
+
+	exit(fmt.Sprint(
+		"Metrics are sent as a JSON event, along with a hash of the user key and a session id\n",
+		"To see the exact data sent for a command, run `privado config metrics --preview`",
+	), false)
+}
+
+// the next command saves its telemetry to a file instead of sending it (see telemetryPostRun)
+func requestTelemetryPreview() {
+	if enabled, source := config.GetMetricsEnabled(); !enabled {
+		exit(fmt.Sprintf("> Telemetry for Privado CLI is disabled (by %s): nothing is sent", source), false)
+	}
+
+	if err := os.MkdirAll(config.AppConfig.StateDirectory, os.ModePerm); err != nil {
+		exit(fmt.Sprintf("Cannot create state directory: %s", err), true)
+	}
+	if err := os.WriteFile(config.AppConfig.TelemetryPreviewRequestFilePath, []byte{}, 0644); err != nil {
+		exit(fmt.Sprintf("Cannot request telemetry preview: %s", err), true)
+	}
+
+	exit(fmt.Sprint(
+		"> Telemetry for the next command (that sends telemetry) will not be sent\n",
+		fmt.Sprintf("The exact request body will be saved at: %s", config.AppConfig.TelemetryPreviewFilePath),
+	), false)
 }
 
 func init() {
 	metricsCmd.Flags().Bool("enable", false, "Enable telemetry events and performance metrics for Privado CLI")
 	metricsCmd.Flags().Bool("disable", false, "Disable telemetry events and performance metrics for Privado CLI")
-	metricsCmd.MarkFlagsMutuallyExclusive("enable", "disable")
 	// [TODO]: Find a way to keep this and privacy.md in sync
-	// metricsCmd.Flags().Bool("list", false, "List down all telemetry events and metrics used by Privado CLI")
+	metricsCmd.Flags().Bool("list", false, "List down all telemetry events and metrics used by Privado CLI")
+	metricsCmd.Flags().Bool("preview", false, "Save the telemetry of the next command to a local file instead of sending it")
+	metricsCmd.MarkFlagsMutuallyExclusive("enable", "disable", "list", "preview")
 
 	configCmd.AddCommand(metricsCmd)
 }
@@ -0,0 +1,231 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ *
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Privado-Inc/privado-cli/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the value of a setting",
+	Args:  cobra.ExactArgs(1),
+	Run:   configGet,
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Update the value of a setting",
+	Args:  cobra.ExactArgs(2),
+	Run:   configSet,
+
+	Annotations: map[string]string{annotationCreatesProfile: "true"},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "Reset a setting to its default value",
+	Args:  cobra.ExactArgs(1),
+	Run:   configUnset,
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all settings with their values and descriptions",
+	Args:  cobra.ExactArgs(0),
+	Run:   configList,
+}
+
+var configResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "Reset all settings to their default values",
+	Args:  cobra.ExactArgs(0),
+	Run:   configReset,
+}
+
+var configProfilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "List all profiles with their settings",
+	Args:  cobra.ExactArgs(0),
+	Run:   configProfiles,
+}
+
+func getUserSettingOrExit(key string) *config.UserSetting {
+	setting, err := config.GetUserSetting(key)
+	if err != nil {
+		exit(fmt.Sprint(err, "\n\nTo list all settings, run `privado config list`"), true)
+	}
+	return setting
+}
+
+func saveUserConfigurationOrExit() {
+	if err := config.SaveUserConfigurationFile(); err != nil {
+		exit(fmt.Sprintf("Cannot save configuration file: %s", err), true)
+	}
+}
+
+// the profile to update: --profile, or the profile from the environment
+func getTargetProfile(cmd *cobra.Command) string {
+	if cmd.Flags().Changed("profile") {
+		profile, _ := cmd.Flags().GetString("profile")
+		return profile
+	}
+	return os.Getenv(config.PrivadoProfileEnvKey)
+}
+
+func getSettingDisplayName(setting *config.UserSetting, profile string) string {
+	if profile != "" {
+		return fmt.Sprintf("%s (profile: %s)", setting.Key, profile)
+	}
+	return setting.Key
+}
+
+func configGet(cmd *cobra.Command, args []string) {
+	setting := getUserSettingOrExit(args[0])
+	exit(setting.Get(config.UserConfig.EffectiveConfig), false)
+}
+
+func configSet(cmd *cobra.Command, args []string) {
+	setting := getUserSettingOrExit(args[0])
+	profile := getTargetProfile(cmd)
+
+	var err error
+	if profile != "" {
+		err = config.SetUserConfigurationProfileSetting(profile, setting, args[1])
+	} else if setting.ProfileOnly {
+		err = fmt.Errorf("%s can only be set in a profile: use `--profile <name>`", setting.Key)
+	} else {
+		err = setting.Set(config.UserConfig.ConfigFile, args[1])
+	}
+	if err != nil {
+		exit(fmt.Sprint(err), true)
+	}
+	saveUserConfigurationOrExit()
+
+	if err := config.SelectUserConfigurationProfile(profile); err != nil {
+		exit(fmt.Sprint(err), true)
+	}
+	exit(fmt.Sprintf("> %s: %s", getSettingDisplayName(setting, profile), setting.Get(config.UserConfig.EffectiveConfig)), false)
+}
+
+func configUnset(cmd *cobra.Command, args []string) {
+	setting := getUserSettingOrExit(args[0])
+	profile := getTargetProfile(cmd)
+
+	if profile != "" {
+		config.UnsetUserConfigurationProfileSetting(profile, setting)
+	} else {
+		setting.Unset(config.UserConfig.ConfigFile)
+	}
+	saveUserConfigurationOrExit()
+
+	if err := config.SelectUserConfigurationProfile(profile); err != nil {
+		exit(fmt.Sprint(err), true)
+	}
+	exit(fmt.Sprintf("> %s: %s", getSettingDisplayName(setting, profile), setting.Get(config.UserConfig.EffectiveConfig)), false)
+}
+
+func configList(cmd *cobra.Command, args []string) {
+	fmt.Println("> Configuration:", config.AppConfig.UserConfigurationFilePath)
+	if config.UserConfig.Profile != "" {
+		fmt.Println("> Profile:", config.UserConfig.Profile)
+	}
+	fmt.Println()
+
+	for _, setting := range config.UserSettings {
+		if setting.ProfileOnly && config.UserConfig.Profile == "" {
+			continue
+		}
+
+		value := setting.Get(config.UserConfig.EffectiveConfig)
+		if value == "" {
+			value = `""`
+		}
+		if config.IsSetInUserConfigurationProfile(setting) {
+			value += " (profile)"
+		} else if setting.IsDefault(config.UserConfig.EffectiveConfig) {
+			value += " (default)"
+		}
+		fmt.Printf("%s = %s\n", setting.Key, value)
+
+		valueType := string(setting.Type)
+		if len(setting.AllowedValues) > 0 {
+			valueType = strings.Join(setting.AllowedValues, " | ")
+		}
+		fmt.Printf("    %s [%s]\n", setting.Description, valueType)
+	}
+	fmt.Println()
+
+	exit("To update a setting, run `privado config set <key> <value>` (with `--profile <name>` for a profile)", false)
+}
+
+func configProfiles(cmd *cobra.Command, args []string) {
+	profiles := config.GetUserConfigurationProfileNames()
+	if len(profiles) == 0 {
+		exit("No profiles found. To create a profile, run `privado config set --profile <name> <key> <value>`", false)
+	}
+
+	for _, profile := range profiles {
+		selected := ""
+		if profile == config.UserConfig.Profile {
+			selected = " (selected)"
+		}
+		fmt.Printf("%s%s\n", profile, selected)
+
+		profileSettings := config.UserConfig.ConfigFile.Profiles[profile]
+		keys := []string{}
+		for key := range profileSettings {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Printf("    %s = %s\n", key, string(profileSettings[key]))
+		}
+	}
+	fmt.Println()
+
+	exit(fmt.Sprintf("To use a profile, run commands with `--profile <name>` or set %s", config.PrivadoProfileEnvKey), false)
+}
+
+func configReset(cmd *cobra.Command, args []string) {
+	if err := config.BootstrapUserConfiguration(true); err != nil {
+		exit(fmt.Sprintf("Cannot reset configuration: %s", err), true)
+	}
+	exit("> Configuration is reset to default values", false)
+}
+
+func init() {
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configUnsetCmd)
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configResetCmd)
+	configCmd.AddCommand(configProfilesCmd)
+}
@@ -0,0 +1,344 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ *
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/Privado-Inc/privado-cli/pkg/auth"
+	"github.com/Privado-Inc/privado-cli/pkg/ci"
+	"github.com/Privado-Inc/privado-cli/pkg/config"
+	"github.com/Privado-Inc/privado-cli/pkg/docker"
+	"github.com/Privado-Inc/privado-cli/pkg/fileutils"
+	"github.com/Privado-Inc/privado-cli/pkg/output"
+	"github.com/Privado-Inc/privado-cli/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+const (
+	doctorStatusPass = "pass"
+	doctorStatusWarn = "warn"
+	doctorStatusFail = "fail"
+)
+
+// thresholds for the environment checks
+const (
+	doctorMinimumDiskSpace     = 2 << 30
+	doctorRecommendedDiskSpace = 10 << 30
+	doctorRecommendedMemory    = 4 << 30
+	doctorEndpointTimeout      = 10 * time.Second
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose the environment for issues running Privado CLI",
+	Long:  "Diagnose the environment for issues running Privado CLI: Docker daemon, image, disk space, host memory and memory available to Docker, permissions, configuration and network. Use `--output-format json` to attach the report to an issue",
+	Args:  cobra.ExactArgs(0),
+	Run:   doctor,
+}
+
+type doctorCheck struct {
+	Name        string `json:"name"`
+	Status      string `json:"status"`
+	Message     string `json:"message"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+type doctorReport struct {
+	Version  string         `json:"version"`
+	Platform string         `json:"platform"`
+	Time     string         `json:"time"`
+	Checks   []*doctorCheck `json:"checks"`
+	Summary  map[string]int `json:"summary"`
+}
+
+func (r *doctorReport) add(name, status, message, remediation string) {
+	r.Checks = append(r.Checks, &doctorCheck{Name: name, Status: status, Message: message, Remediation: remediation})
+	r.Summary[status]++
+}
+
+func doctor(cmd *cobra.Command, args []string) {
+
+	report := &doctorReport{
+		Version:  Version,
+		Platform: fmt.Sprintf("%s-%s", runtime.GOOS, runtime.GOARCH),
+		Time:     time.Now().UTC().Format(time.RFC3339),
+		Checks:   []*doctorCheck{},
+		Summary:  map[string]int{doctorStatusPass: 0, doctorStatusWarn: 0, doctorStatusFail: 0},
+	}
+
+	output.Println("> Running diagnostics..")
+	output.Println()
+
+	checkDockerDaemon(report)
+	checkHostMemory(report)
+	checkDirectoryWritable(report, "Configuration directory", config.AppConfig.ConfigurationDirectory)
+	checkDirectoryWritable(report, "Cache directory", config.AppConfig.CacheDirectory)
+	checkDiskSpace(report, "Cache disk space", config.AppConfig.CacheDirectory)
+	checkDirectoryWritable(report, "State directory", config.AppConfig.StateDirectory)
+	checkBinaryWritable(report)
+	checkUserConfiguration(report)
+	checkUserKey(report)
+	checkCI(report)
+	checkEndpoint(report, "Update endpoint", fmt.Sprint(config.ExtConfig.GitHubAPIHost, strings.Replace(config.ExtConfig.GitHubReleasesEndpoint, "${REPO_NAME}", config.AppConfig.PrivadoRepositoryName, 1)))
+	checkEndpoint(report, "Telemetry endpoint", config.AppConfig.PrivadoTelemetryEndpoint)
+
+	hasFailures := report.Summary[doctorStatusFail] > 0
+
+	// in the json format, the report is emitted as an event before the exit event
+	output.Emit(output.Event{Event: output.EventReport, Report: report})
+	for _, check := range report.Checks {
+		output.Printf("[%s] %s: %s\n", strings.ToUpper(check.Status), check.Name, check.Message)
+		if check.Remediation != "" {
+			output.Printf("       > %s\n", check.Remediation)
+		}
+	}
+	output.Println()
+
+	summary := fmt.Sprintf("> %d passed, %d warnings, %d failed", report.Summary[doctorStatusPass], report.Summary[doctorStatusWarn], report.Summary[doctorStatusFail])
+	if hasFailures {
+		summary += fmt.Sprint("\n\nIf you need help, run `privado doctor --output-format json` and attach the report to an issue at ", config.AppConfig.PrivadoRepository)
+	}
+	exit(summary, hasFailures)
+}
+
+// daemon reachability, socket permission, image, memory of the daemon and disk space of the data directory
+// the memory is that of the daemon (e.g. the Docker Desktop VM), not of the host
+func checkDockerDaemon(report *doctorReport) {
+	daemonHost := docker.GetDaemonHost()
+	// the socket permission is evaluated by connecting to the daemon
+	daemon, err := docker.GetDaemonDiagnostics()
+	if strings.HasPrefix(daemonHost, "unix://") {
+		socketPath := strings.TrimPrefix(daemonHost, "unix://")
+		if exists, _ := fileutils.DoesFileExists(socketPath); !exists {
+			report.add("Docker socket", doctorStatusFail, fmt.Sprintf("%s does not exist", socketPath), "Start Docker (or Podman), or set DOCKER_HOST to the socket of a running daemon")
+		} else if err != nil && isPermissionError(err) {
+			report.add("Docker socket", doctorStatusFail, fmt.Sprintf("No permission to access %s", socketPath), "Add your user to the docker group (sudo usermod -aG docker $USER) and log in again")
+		} else {
+			report.add("Docker socket", doctorStatusPass, socketPath, "")
+		}
+	}
+
+	if err != nil {
+		report.add("Docker daemon", doctorStatusFail, fmt.Sprintf("Cannot connect to %s: %s", daemonHost, err), "Make sure Docker (or Podman) is installed and running: https://docs.docker.com/get-docker/")
+		return
+	}
+	report.add("Docker daemon", doctorStatusPass, fmt.Sprintf("%s %s (API %s, %s)", daemon.Engine, daemon.ServerVersion, daemon.APIVersion, daemon.OperatingSystem), "")
+
+	image := config.AppConfig.Container.ImageURL
+	if imageInfo, err := docker.GetImageDiagnostics(image); err != nil {
+		report.add("Privado image", doctorStatusWarn, fmt.Sprintf("Cannot inspect %s: %s", image, err), "")
+	} else if imageInfo == nil {
+		report.add("Privado image", doctorStatusWarn, fmt.Sprintf("%s is not present", image), "The image is pulled on the next scan, or pull it now: docker pull "+image)
+	} else {
+		report.add("Privado image", doctorStatusPass, fmt.Sprintf("%s (%s, %s)", image, imageInfo.Digest, formatBytes(uint64(imageInfo.Size))), "")
+	}
+
+	if daemon.MemTotal < doctorRecommendedMemory {
+		report.add("Docker memory", doctorStatusWarn, fmt.Sprintf("%s available to Docker", formatBytes(uint64(daemon.MemTotal))), fmt.Sprintf("Scans of large repositories may run out of memory; allocate at least %s to Docker", formatBytes(doctorRecommendedMemory)))
+	} else {
+		report.add("Docker memory", doctorStatusPass, fmt.Sprintf("%s available to Docker", formatBytes(uint64(daemon.MemTotal))), "")
+	}
+
+	// the data directory is not accessible from the host when the daemon runs in a VM (Docker Desktop)
+	if exists, _ := fileutils.DoesFileExists(daemon.DockerRootDir); exists {
+		checkDiskSpace(report, "Docker data disk space", daemon.DockerRootDir)
+	} else {
+		report.add("Docker data disk space", doctorStatusPass, fmt.Sprintf("%s is not accessible from the host; skipped", daemon.DockerRootDir), "")
+	}
+}
+
+// memory of the host, which limits the memory of the daemon (and its VM)
+func checkHostMemory(report *doctorReport) {
+	total, available, err := utils.GetHostMemory()
+	if err != nil {
+		report.add("Host memory", doctorStatusWarn, fmt.Sprintf("Cannot evaluate host memory: %s", err), "")
+		return
+	}
+
+	message := fmt.Sprintf("%s total", formatBytes(total))
+	if available > 0 {
+		message = fmt.Sprintf("%s available of %s", formatBytes(available), formatBytes(total))
+	}
+	if total < doctorRecommendedMemory {
+		report.add("Host memory", doctorStatusWarn, message, fmt.Sprintf("Scans of large repositories may run out of memory; at least %s is recommended", formatBytes(doctorRecommendedMemory)))
+		return
+	}
+	report.add("Host memory", doctorStatusPass, message, "")
+}
+
+// the docker client reports socket permission errors in the message
+func isPermissionError(err error) bool {
+	return errors.Is(err, fs.ErrPermission) || strings.Contains(strings.ToLower(err.Error()), "permission denied")
+}
+
+func checkDiskSpace(report *doctorReport, name, path string) {
+	// a missing directory is on the filesystem of its nearest existing parent
+	if existingDirectory, err := getNearestExistingDirectory(path); err == nil {
+		path = existingDirectory
+	}
+
+	available, err := fileutils.GetAvailableDiskSpace(path)
+	switch {
+	case err != nil:
+		report.add(name, doctorStatusWarn, fmt.Sprintf("Cannot evaluate free disk space for %s: %s", path, err), "")
+	case available < doctorMinimumDiskSpace:
+		report.add(name, doctorStatusFail, fmt.Sprintf("%s free in %s", formatBytes(available), path), "Free up disk space, e.g. remove unused images with `docker system prune`")
+	case available < doctorRecommendedDiskSpace:
+		report.add(name, doctorStatusWarn, fmt.Sprintf("%s free in %s", formatBytes(available), path), "Scans with dependency downloads may run out of disk space")
+	default:
+		report.add(name, doctorStatusPass, fmt.Sprintf("%s free in %s", formatBytes(available), path), "")
+	}
+}
+
+// a directory is writable if a file can be created in it
+// directories are created on demand by the CLI, a missing directory is
+// checked by its nearest existing parent (without creating the directory)
+func checkDirectoryWritable(report *doctorReport, name, directory string) {
+	if directory == "" {
+		report.add(name, doctorStatusFail, "Not available", "")
+		return
+	}
+
+	existingDirectory, err := getNearestExistingDirectory(directory)
+	if err != nil {
+		report.add(name, doctorStatusFail, fmt.Sprintf("Cannot evaluate %s: %s", directory, err), fmt.Sprintf("Fix the permissions, or set %s to a writable directory", config.PrivadoHomeEnvKey))
+		return
+	}
+
+	file, err := os.CreateTemp(existingDirectory, ".doctor-")
+	if err != nil {
+		report.add(name, doctorStatusFail, fmt.Sprintf("Cannot write to %s: %s", existingDirectory, err), fmt.Sprintf("Fix the ownership and permissions of %s, or set %s to a writable directory", existingDirectory, config.PrivadoHomeEnvKey))
+		return
+	}
+	file.Close()
+	os.Remove(file.Name())
+
+	if existingDirectory != directory {
+		report.add(name, doctorStatusPass, fmt.Sprintf("%s does not exist, and can be created in %s", directory, existingDirectory), "")
+		return
+	}
+	report.add(name, doctorStatusPass, fmt.Sprintf("%s is writable", directory), "")
+}
+
+// returns the directory, or its nearest parent directory that exists
+func getNearestExistingDirectory(directory string) (string, error) {
+	directory = filepath.Clean(directory)
+	for {
+		info, err := os.Stat(directory)
+		if err == nil {
+			if !info.IsDir() {
+				return "", fmt.Errorf("%s is not a directory", directory)
+			}
+			return directory, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+
+		parent := filepath.Dir(directory)
+		if parent == directory {
+			return "", err
+		}
+		directory = parent
+	}
+}
+
+func checkBinaryWritable(report *doctorReport) {
+	binaryPath, err := fileutils.GetPathToCurrentBinary()
+	if err != nil {
+		report.add("Installation", doctorStatusWarn, fmt.Sprintf("Cannot evaluate path to the current binary: %s", err), "")
+		return
+	}
+
+	if hasPerm, err := fileutils.HasWritePermissionToFile(binaryPath); err != nil || !hasPerm {
+		report.add("Installation", doctorStatusWarn, fmt.Sprintf("%s is not writable", binaryPath), "`privado update` requires a privileged user (sudo)")
+		return
+	}
+	report.add("Installation", doctorStatusPass, fmt.Sprintf("%s is writable", binaryPath), "")
+}
+
+func checkUserConfiguration(report *doctorReport) {
+	if err := config.LoadUserConfigurationFile(&config.UserConfigurationFromFile{}); err != nil {
+		report.add("Configuration file", doctorStatusFail, fmt.Sprintf("Cannot load %s: %s", config.AppConfig.UserConfigurationFilePath, err), "Fix the file, or delete it to generate a new one")
+		return
+	}
+	report.add("Configuration file", doctorStatusPass, config.AppConfig.UserConfigurationFilePath, "")
+}
+
+func checkUserKey(report *doctorReport) {
+	if err := auth.VerifyUserKeyFile(config.AppConfig.UserKeyPath); err != nil {
+		report.add("User key", doctorStatusFail, fmt.Sprintf("Invalid user key (%s): %s", config.AppConfig.UserKeyPath, err), "Delete the file to generate a new user key")
+		return
+	}
+	report.add("User key", doctorStatusPass, config.AppConfig.UserKeyPath, "")
+}
+
+func checkCI(report *doctorReport) {
+	if !ci.CISessionConfig.IsCI {
+		report.add("CI", doctorStatusPass, "Not detected", "")
+		return
+	}
+
+	provider := "unidentified provider"
+	if ci.CISessionConfig.Provider != nil {
+		provider = ci.CISessionConfig.Provider.Name
+	}
+	report.add("CI", doctorStatusPass, fmt.Sprintf("Detected (%s)", provider), "")
+}
+
+func checkEndpoint(report *doctorReport, name, url string) {
+	if _, err := utils.CheckURLReachability(url, doctorEndpointTimeout); err != nil {
+		report.add(name, doctorStatusWarn, fmt.Sprintf("Cannot reach %s: %s", url, err), "Check your network or proxy configuration (HTTPS_PROXY, NO_PROXY). Behind a TLS-inspecting proxy, set the CA bundle: `privado config set caBundlePath <path>`")
+		return
+	}
+	report.add(name, doctorStatusPass, fmt.Sprintf("%s is reachable", url), "")
+}
+
+// formats bytes in binary units: 512 B, 1.5 KiB, 2.0 GiB
+func formatBytes(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+
+	div, exp := uint64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	doctorCmd.Flags().String("output-format", output.FormatText, "Specifies the output format: 'text', or 'json' to print the report as JSON")
+	rootCmd.AddCommand(doctorCmd)
+}
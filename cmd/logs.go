@@ -0,0 +1,133 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ *
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/Privado-Inc/privado-cli/pkg/ci"
+	"github.com/Privado-Inc/privado-cli/pkg/config"
+	"github.com/Privado-Inc/privado-cli/pkg/output"
+	"github.com/Privado-Inc/privado-cli/pkg/runlog"
+	"github.com/spf13/cobra"
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "List and view logs of previous runs of Privado CLI",
+	Long:  "List and view logs of previous runs of Privado CLI. Each scan, upload and validate run stores a log with the complete container output",
+	Args:  cobra.ExactArgs(0),
+	Run:   logs,
+}
+
+func logs(cmd *cobra.Command, args []string) {
+	showLast, _ := cmd.Flags().GetBool("last")
+	runId, _ := cmd.Flags().GetString("run")
+
+	var entry *runlog.RunLogEntry
+	var err error
+
+	if showLast {
+		entry, err = runlog.GetLastRunLog()
+	} else if runId != "" {
+		entry, err = runlog.GetRunLog(runId)
+	} else {
+		// if no flags are specified, list available run logs
+		entries, err := runlog.ListRunLogs()
+		if err != nil {
+			exit(fmt.Sprintf("Cannot list run logs: %s", err), true)
+		}
+		if len(entries) == 0 {
+			exit("No run logs found", false)
+		}
+
+		fmt.Println("> Run logs:", runlog.GetRunLogDirectory())
+		fmt.Println()
+		for _, entry := range entries {
+			fmt.Printf("%s\t%s\t%d bytes\n", entry.Id, entry.ModTime.Format("2006-01-02 15:04:05"), entry.Size)
+		}
+		fmt.Println()
+		exit("To view a run log, run `privado logs --run <id>` or `privado logs --last`", false)
+	}
+
+	if err != nil {
+		exit(fmt.Sprintf("Cannot find run log: %s", err), true)
+	}
+
+	file, err := os.Open(entry.Path)
+	if err != nil {
+		exit(fmt.Sprintf("Cannot open run log: %s", err), true)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(os.Stdout, file); err != nil {
+		exit(fmt.Sprintf("Cannot read run log: %s", err), true)
+	}
+}
+
+func defineRunLogFlags(cmd *cobra.Command) {
+	cmd.Flags().String("log-file", "", "Additionally writes the run log, including the complete container output, to the specified file")
+}
+
+// initiates the run log for commands that run the privado image
+// a failure to initiate is not fatal, unless a log file is explicitly requested
+func runLogPreRun(cmd *cobra.Command, args []string) {
+	logFile, _ := cmd.Flags().GetString("log-file")
+
+	runLog, err := runlog.InitiateRunLog(logFile)
+	if err != nil {
+		if logFile != "" {
+			exit(fmt.Sprintf("Cannot create log file (%s): %s", logFile, err), true)
+		}
+		output.Println("[WARN]: Cannot create run log:", err)
+		output.Emit(output.Event{Event: output.EventWarning, Message: fmt.Sprintf("Cannot create run log: %s", err)})
+		return
+	}
+	runlog.DefaultInstance = runLog
+
+	runLog.LogSection("Privado CLI run", []string{
+		fmt.Sprintf("Run ID: %s", runLog.Id),
+		fmt.Sprintf("Version: %s (%s-%s)", Version, runtime.GOOS, runtime.GOARCH),
+		fmt.Sprintf("Command: %s", strings.Join(os.Args, " ")),
+		fmt.Sprintf("CI: %t", ci.CISessionConfig.IsCI),
+		fmt.Sprintf("Profile: %s", config.UserConfig.Profile),
+		fmt.Sprintf("Image: %s", config.AppConfig.Container.ImageURL),
+	})
+}
+
+func runLogPostRun(exitStatus int) {
+	runlog.DefaultInstance.Close(exitStatus)
+}
+
+func init() {
+	logsCmd.Flags().Bool("last", false, "Print the log of the most recent run")
+	logsCmd.Flags().String("run", "", "Print the log of the run with the specified id")
+	logsCmd.MarkFlagsMutuallyExclusive("last", "run")
+
+	rootCmd.AddCommand(logsCmd)
+}
@@ -24,6 +24,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -32,30 +33,36 @@ import (
 
 	"github.com/Privado-Inc/privado-cli/pkg/ci"
 	"github.com/Privado-Inc/privado-cli/pkg/config"
+	"github.com/Privado-Inc/privado-cli/pkg/docker"
+	"github.com/Privado-Inc/privado-cli/pkg/fileutils"
+	"github.com/Privado-Inc/privado-cli/pkg/output"
+	"github.com/Privado-Inc/privado-cli/pkg/runlog"
 	"github.com/Privado-Inc/privado-cli/pkg/telemetry"
+	"github.com/Privado-Inc/privado-cli/pkg/utils"
+	"github.com/moby/term"
 	"github.com/spf13/cobra"
 )
 
 var Version = "dev"
 
 var rootCmd = &cobra.Command{
-	Use:   "privado",
-	Short: "Privado is a CLI tool that scans & monitors your repositories to build privacy, transparency reports & finds privacy issues",
-	Long:  "Privado is a CLI tool that scans & monitors your repositories to build privacy, transparency reports & finds privacy issues. \nFind more at: https://github.com/Privado-Inc/privado",
+	Use:              "privado",
+	Short:            "Privado is a CLI tool that scans & monitors your repositories to build privacy, transparency reports & finds privacy issues",
+	Long:             "Privado is a CLI tool that scans & monitors your repositories to build privacy, transparency reports & finds privacy issues. \nFind more at: https://github.com/Privado-Inc/privado",
+	PersistentPreRun: rootPersistentPreRun,
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		exit(fmt.Sprintln(err), true)
-	}
+	utils.SetHTTPUserAgentVersion(Version)
 
+	// registered before the command runs, to record panics of the command
 	defer func() {
 		// if panic occurred
 		if err := recover(); err != nil {
-			// only if we have a docker access hash
-			if config.UserConfig.DockerAccessHash != "" {
+			// only if we have a docker access hash, and telemetry is enabled
+			if config.UserConfig.DockerAccessHash != "" && config.IsMetricsEnabled() {
 				// if defaultInstance is already sent, create another, else append to error and send
-				if !telemetry.DefaultInstance.Recorded {
+				if !telemetry.DefaultInstance.IsRecorded() {
 					telemetry.DefaultInstance.RecordArrayMetric("error", err)
 					telemetryPostRun(nil)
 				} else {
@@ -65,8 +72,177 @@ func Execute() {
 					telemetryPostRun(t)
 				}
 			}
+			// crash as before (the panic is not handled, only recorded)
+			panic(err)
 		}
 	}()
+
+	if err := rootCmd.Execute(); err != nil {
+		exit(fmt.Sprintln(err), true)
+	}
+}
+
+// the telemetry is saved to a file instead of being sent
+var isTelemetryPreview = false
+
+// annotation for commands that create the profile (--profile) if it does not exist
+const annotationCreatesProfile = "createsProfile"
+
+func rootPersistentPreRun(cmd *cobra.Command, args []string) {
+	// the output format is set first, so that errors are reported in the format
+	if outputFormatFlag := cmd.Flags().Lookup("output-format"); outputFormatFlag != nil {
+		if err := output.SetFormat(outputFormatFlag.Value.String()); err != nil {
+			exit(fmt.Sprint(err), true)
+		}
+		// json output is consumed by tools, which cannot answer prompts
+		if output.IsJSON() {
+			setNonInteractive()
+		}
+	}
+
+	// the profile from the environment is selected when loading the configuration
+	if cmd.Flags().Changed("profile") {
+		profile, _ := cmd.Flags().GetString("profile")
+		if err := config.SelectUserConfigurationProfile(profile); err != nil {
+			if _, createsProfile := cmd.Annotations[annotationCreatesProfile]; !createsProfile {
+				exit(fmt.Sprint(err), true)
+			}
+		}
+	}
+
+	// an invalid channel in the environment is not silently ignored
+	if _, err := config.GetChannel(); err != nil {
+		exit(fmt.Sprint(err), true)
+	}
+
+	// requested by `privado config metrics --preview` for the next command
+	if exists, _ := fileutils.DoesFileExists(config.AppConfig.TelemetryPreviewRequestFilePath); exists {
+		isTelemetryPreview = true
+	}
+
+	// non-interactive mode is enabled automatically when there is no
+	// one to interact with: stdin is not a terminal (pipes, schedulers) or CI
+	nonInteractive, _ := cmd.Flags().GetBool("non-interactive")
+	if nonInteractive || ci.CISessionConfig.IsCI || !term.IsTerminal(os.Stdin.Fd()) {
+		setNonInteractive()
+	}
+}
+
+// disables prompts, TTY allocation and stdin forwarding for the container,
+// artificial slowdowns, and ANSI escape sequences in the output
+func setNonInteractive() {
+	output.SetInteractive(false)
+	config.AppConfig.SlowdownTime = 0
+}
+
+// flags shared by the commands that run the privado image
+func defineImageCommandFlags(cmd *cobra.Command) {
+	cmd.Flags().String("output-format", output.FormatText, "Specifies the output format: 'text', or 'json' to print newline-delimited JSON events instead of messages")
+	defineRunLogFlags(cmd)
+}
+
+// flags for the commands that receive a dashboard URL from the image
+func defineDashboardFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("no-browser", false, "Does not open the dashboard in a browser. The URL is saved in the results metadata (.privado/metadata.json) instead")
+	cmd.Flags().String("dashboard-url-file", "", "Additionally writes the dashboard URL to the specified file")
+}
+
+// flags for the commands where the engine makes network requests
+func defineNetworkFlags(cmd *cobra.Command) {
+	cmd.Flags().String("http-proxy", "", "Proxy for HTTP requests of the scan engine (default: HTTP_PROXY of the host)")
+	cmd.Flags().String("https-proxy", "", "Proxy for HTTPS requests of the scan engine (default: HTTPS_PROXY of the host)")
+	cmd.Flags().String("no-proxy", "", "Hosts to reach without the proxy, comma separated (default: NO_PROXY of the host)")
+	cmd.Flags().String("ca-bundle", "", fmt.Sprintf("PEM bundle of additional CA certificates trusted by the scan engine (default: %s, or the caBundlePath setting)", config.CABundlePathEnvKey))
+}
+
+// the proxy for the container: flags, or the proxy environment of the host
+func getProxyConfiguration(cmd *cobra.Command) docker.ProxyConfiguration {
+	getProxyValue := func(flag, envKey string) string {
+		if value, _ := cmd.Flags().GetString(flag); value != "" {
+			return value
+		}
+		if value := os.Getenv(envKey); value != "" {
+			return value
+		}
+		return os.Getenv(strings.ToLower(envKey))
+	}
+
+	proxy := docker.ProxyConfiguration{
+		HTTPProxy:  getProxyValue("http-proxy", "HTTP_PROXY"),
+		HTTPSProxy: getProxyValue("https-proxy", "HTTPS_PROXY"),
+		NoProxy:    getProxyValue("no-proxy", "NO_PROXY"),
+	}
+
+	for _, proxyValue := range []string{proxy.HTTPProxy, proxy.HTTPSProxy} {
+		if docker.IsLoopbackProxy(proxyValue) {
+			warning := "The proxy is on localhost, which is not reachable from the scan engine container. Use an address of the host reachable from containers (e.g. host.docker.internal)"
+			output.Println("[WARN]: ", warning)
+			output.Emit(output.Event{Event: output.EventWarning, Message: warning})
+			telemetry.DefaultInstance.RecordArrayMetric("warning", warning)
+			break
+		}
+	}
+	return proxy
+}
+
+// prepares the certificates for the container from the CA bundle (--ca-bundle,
+// or the configured bundle); empty when no bundle is configured
+func getContainerCertificatesDirectory(cmd *cobra.Command) string {
+	caBundlePath, _ := cmd.Flags().GetString("ca-bundle")
+	if caBundlePath != "" {
+		caBundlePath = fileutils.GetAbsolutePath(caBundlePath)
+	} else {
+		caBundlePath = config.GetCABundlePath()
+	}
+	if caBundlePath == "" {
+		return ""
+	}
+
+	certificatesDirectory, err := docker.PrepareContainerCertificates(caBundlePath)
+	if err != nil {
+		exit(fmt.Sprintf("Cannot use CA bundle (%s): %s", caBundlePath, err), true)
+	}
+	runlog.DefaultInstance.Printf("CA bundle: %s", caBundlePath)
+	return certificatesDirectory
+}
+
+// the browser is disabled by flag or configuration, and
+// automatically for environments where it cannot be opened
+func isBrowserDisabled(cmd *cobra.Command) bool {
+	noBrowser, _ := cmd.Flags().GetBool("no-browser")
+
+	reason := ""
+	if noBrowser {
+		reason = "--no-browser"
+	} else if !config.UserConfig.EffectiveConfig.OpenBrowser {
+		reason = fmt.Sprintf("openBrowser is disabled in %s", config.AppConfig.UserConfigurationFilePath)
+	} else if ci.CISessionConfig.IsCI {
+		reason = "CI environment"
+	} else {
+		reason = utils.GetHeadlessEnvironmentReason()
+	}
+
+	if reason != "" {
+		runlog.DefaultInstance.Printf("Browser disabled: %s", reason)
+		return true
+	}
+	return false
+}
+
+func imageCommandPreRun(cmd *cobra.Command, args []string) {
+	// root span of the run, the phases are recorded as its children
+	rootSpan := telemetry.StartSpan(fmt.Sprintf("privado %s", cmd.Name()))
+	rootSpan.SetAttribute("ci", ci.CISessionConfig.IsCI)
+
+	telemetryPreRun(nil)
+	runLogPreRun(cmd, args)
+}
+
+func imageCommandPostRun(cmd *cobra.Command, args []string) {
+	output.Emit(output.Event{Event: output.EventExit, ExitStatus: output.ExitStatus(0)})
+	runLogPostRun(0)
+	tracesPostRun(nil)
+	telemetryPostRun(nil)
 }
 
 func telemetryPreRun(t *telemetry.Telemetry) {
@@ -87,27 +263,155 @@ func telemetryPostRun(t *telemetry.Telemetry) {
 		t = telemetry.DefaultInstance
 	}
 
-	t.PostRecordedTelemetry(telemetry.TelemetryRequestConfig{
+	reqConfig := telemetry.TelemetryRequestConfig{
 		Url:                   config.AppConfig.PrivadoTelemetryEndpoint,
 		UserHash:              config.UserConfig.UserHash,
 		SessionId:             config.UserConfig.SessionId,
 		AuthenticationKeyHash: config.UserConfig.DockerAccessHash,
-	})
+		QueueDirectory:        config.AppConfig.TelemetryQueueDirectory,
+	}
+
+	if isTelemetryPreview {
+		os.Remove(config.AppConfig.TelemetryPreviewRequestFilePath)
+		if err := t.SaveRecordedTelemetry(reqConfig, config.AppConfig.TelemetryPreviewFilePath); err != nil {
+			output.Println("[WARN]: Could not save telemetry preview:", err)
+		} else {
+			output.Println("\n> Telemetry preview saved at (not sent):", config.AppConfig.TelemetryPreviewFilePath)
+		}
+		return
+	}
+
+	if !config.IsMetricsEnabled() {
+		return
+	}
+	t.PostRecordedTelemetry(reqConfig)
+}
+
+// exports the spans of the run as OTLP/JSON traces, when configured;
+// this is independent of the telemetry sent to Privado
+func tracesPostRun(err error) {
+	spans := telemetry.DefaultTracer.FinishSpans(telemetry.DefaultInstance, err)
+	tracesFile := config.UserConfig.EffectiveConfig.TracesFile
+	tracesEndpoint := config.GetTracesEndpoint()
+	if len(spans) == 0 || (tracesFile == "" && tracesEndpoint == "") {
+		return
+	}
+
+	requestBody, err := telemetry.DefaultTracer.GetOTLPRequestBody(spans, Version)
+	if err != nil {
+		output.Println("[WARN]: Could not encode traces:", err)
+		return
+	}
+
+	if tracesFile != "" {
+		if err := telemetry.ExportOTLPToFile(requestBody, tracesFile); err != nil {
+			output.Println("[WARN]: Could not export traces to file:", err)
+		}
+	}
+	if tracesEndpoint != "" {
+		if err := telemetry.ExportOTLPToEndpoint(requestBody, tracesEndpoint); err != nil {
+			output.Println("[WARN]: Could not export traces to collector:", err)
+		}
+	}
 }
 
 func exit(msg string, error bool) {
-	fmt.Println(msg)
+	if error {
+		exitWithStatus(msg, 1)
+	} else {
+		exitWithStatus(msg, 0)
+	}
+}
+
+// similar to exit, with a specific exit status (non-zero for errors)
+func exitWithStatus(msg string, exitStatus int) {
+	error := exitStatus != 0
+
+	output.Println(msg)
 	if error {
 		telemetry.DefaultInstance.RecordArrayMetric("error", msg)
 	}
 
-	if !telemetry.DefaultInstance.Recorded && config.UserConfig.DockerAccessHash != "" {
-		telemetryPostRun(nil)
+	exitEvent := output.Event{Event: output.EventExit, Message: strings.TrimSpace(msg), ExitStatus: output.ExitStatus(int64(exitStatus))}
+	if runlog.DefaultInstance != nil {
+		runlog.DefaultInstance.Printf("Exit: %s", strings.TrimSpace(msg))
+		if error {
+			output.Println("\n> Run log saved at:", runlog.DefaultInstance.Path)
+			exitEvent.Path = runlog.DefaultInstance.Path
+		}
+		runLogPostRun(exitStatus)
 	}
+	output.Emit(exitEvent)
 
+	// spans that are not finished fail with the exit message
 	if error {
-		os.Exit(1)
+		tracesPostRun(errors.New(strings.TrimSpace(msg)))
 	} else {
-		os.Exit(0)
+		tracesPostRun(nil)
+	}
+
+	if !telemetry.DefaultInstance.IsRecorded() && config.UserConfig.DockerAccessHash != "" && config.IsMetricsEnabled() {
+		telemetryPostRun(nil)
+	}
+
+	os.Exit(exitStatus)
+}
+
+// loads the engine error catalogue, extended by the user catalogue in the configuration directory
+func loadEngineErrorCatalogue() []*docker.EngineErrorClass {
+	catalogue, warnings := docker.LoadEngineErrorCatalogue(config.AppConfig.ErrorCatalogueFilePath)
+	for _, warning := range warnings {
+		output.Println("[WARN]: ", warning)
+		output.Emit(output.Event{Event: output.EventWarning, Message: warning})
+		telemetry.DefaultInstance.RecordArrayMetric("warning", warning)
+	}
+	return catalogue
+}
+
+// exits for errors returned by docker.RunImage
+// interrupted runs exit with the conventional status for the signal (130, 143)
+// classified engine errors are explained with a remediation and a specific exit status
+// other engine failures exit with the status code of the container
+func exitOnRunImageError(err error) {
+	var interruptedError *docker.InterruptedError
+	if errors.As(err, &interruptedError) {
+		exitWithStatus(fmt.Sprint("\n> Terminated: ", interruptedError), utils.GetExitStatusForSignal(interruptedError.Signal))
+	}
+
+	var engineError *docker.EngineError
+	if errors.As(err, &engineError) {
+		telemetry.DefaultInstance.RecordAtomicMetric("errorClass", engineError.Class.Id)
+		output.Emit(output.Event{
+			Event:       output.EventError,
+			ErrorClass:  engineError.Class.Id,
+			Message:     engineError.Error(),
+			Remediation: engineError.Class.Remediation,
+		})
+
+		output.Println("\n> The scan engine failed:", engineError.Class.Description)
+		if engineError.Message != "" {
+			output.Println("> Identified from the output:", engineError.Message)
+		}
+		if engineError.Class.Remediation != "" {
+			output.Println("\n> Suggested fix:", engineError.Class.Remediation)
+		}
+		output.Println()
+		exitWithStatus(fmt.Sprint("If this does not resolve the issue, please raise an issue at ", config.AppConfig.PrivadoRepository), engineError.Class.ExitCode)
 	}
+
+	var containerExitError *docker.ContainerExitError
+	if errors.As(err, &containerExitError) {
+		exitStatus := int(containerExitError.StatusCode)
+		if exitStatus < 1 || exitStatus > 255 {
+			exitStatus = 1
+		}
+		exitWithStatus(fmt.Sprintf("\n> The scan engine exited with status code: %d", containerExitError.StatusCode), exitStatus)
+	}
+
+	exit(fmt.Sprintf("Received error: %s", err), true)
+}
+
+func init() {
+	rootCmd.PersistentFlags().String("profile", "", fmt.Sprintf("Use the settings of the named profile in the configuration (default: %s)", config.PrivadoProfileEnvKey))
+	rootCmd.PersistentFlags().Bool("non-interactive", false, "Never prompt or allocate a TTY, and print plain output without ANSI escape sequences. Enabled automatically when stdin is not a terminal or CI is detected")
 }
@@ -25,6 +25,7 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -34,21 +35,18 @@ import (
 	"github.com/Privado-Inc/privado-cli/pkg/config"
 	"github.com/Privado-Inc/privado-cli/pkg/docker"
 	"github.com/Privado-Inc/privado-cli/pkg/fileutils"
+	"github.com/Privado-Inc/privado-cli/pkg/output"
 	"github.com/Privado-Inc/privado-cli/pkg/utils"
 	"github.com/spf13/cobra"
 )
 
 var scanCmd = &cobra.Command{
-	Use:   "scan <repository>",
-	Short: "Scan a codebase or repository to identify privacy issues and generate compliance reports",
-	Args:  cobra.ExactArgs(1),
-	PreRun: func(cmd *cobra.Command, args []string) {
-		telemetryPreRun(nil)
-	},
-	Run: scan,
-	PostRun: func(cmd *cobra.Command, args []string) {
-		telemetryPostRun(nil)
-	},
+	Use:     "scan <repository>",
+	Short:   "Scan a codebase or repository to identify privacy issues and generate compliance reports",
+	Args:    cobra.ExactArgs(1),
+	PreRun:  imageCommandPreRun,
+	Run:     scan,
+	PostRun: imageCommandPostRun,
 }
 
 func defineScanFlags(cmd *cobra.Command) {
@@ -85,6 +83,7 @@ func scan(cmd *cobra.Command, args []string) {
 	debug, _ := cmd.Flags().GetBool("debug")
 	overwriteResults, _ := cmd.Flags().GetBool("overwrite")
 	skipDependencyDownload, _ := cmd.Flags().GetBool("skip-dependency-download")
+	skipDependencyDownload = skipDependencyDownload || config.UserConfig.EffectiveConfig.SkipDependencyDownload
 	disableDeduplication, _ := cmd.Flags().GetBool("disable-deduplication")
 	explicitUpload, _ := cmd.Flags().GetBool("upload")
 	explicitSkipUpload, _ := cmd.Flags().GetBool("skip-upload")
@@ -103,8 +102,16 @@ func scan(cmd *cobra.Command, args []string) {
 	enableAuditSemantic, _ := cmd.Flags().GetBool("enable-audit-semantic")
 	enableLambdaFlows, _ := cmd.Flags().GetBool("enable-lambda-flows")
 	isMonolith, _ := cmd.Flags().GetBool("monolith")
+	dashboardURLFile, _ := cmd.Flags().GetString("dashboard-url-file")
+	if dashboardURLFile != "" {
+		dashboardURLFile = fileutils.GetAbsolutePath(dashboardURLFile)
+	}
 
 	externalRules, _ := cmd.Flags().GetString("config")
+	if externalRules == "" {
+		// default from the configuration (or profile)
+		externalRules = config.UserConfig.EffectiveConfig.RulesDirectory
+	}
 	if externalRules != "" {
 		externalRules = fileutils.GetAbsolutePath(externalRules)
 		externalRulesExists, _ := fileutils.DoesFileExists(externalRules)
@@ -124,17 +131,21 @@ func scan(cmd *cobra.Command, args []string) {
 
 	hasUpdate, updateMessage, err := checkForUpdate()
 	if err == nil && hasUpdate {
-		fmt.Println(updateMessage)
+		output.Println(updateMessage)
 		time.Sleep(config.AppConfig.SlowdownTime)
-		fmt.Println("To use the latest version of Privado CLI, run `privado update`")
+		output.Println("To use the latest version of Privado CLI, run `privado update`")
 		time.Sleep(config.AppConfig.SlowdownTime)
-		fmt.Println()
+		output.Println()
 	}
 
 	// if overwrite flag is not specified, check for existing results
 	if !overwriteResults {
 		resultsPath := filepath.Join(fileutils.GetAbsolutePath(repository), config.AppConfig.PrivacyResultsPathSuffix)
 		if exists, _ := fileutils.DoesFileExists(resultsPath); exists {
+			// prompts cannot be answered in non-interactive mode (CI, pipes, json output)
+			if !output.IsInteractive() {
+				exit(fmt.Sprintf("Scan report already exists (%s). Use `--overwrite` to overwrite existing results", config.AppConfig.PrivacyResultsPathSuffix), true)
+			}
 			fmt.Printf("> Scan report already exists (%s)\n", config.AppConfig.PrivacyResultsPathSuffix)
 			fmt.Println("\n> Rescan will overwrite existing results")
 			confirm, _ := utils.ShowConfirmationPrompt("Continue?")
@@ -152,7 +163,7 @@ func scan(cmd *cobra.Command, args []string) {
 		), true)
 	}
 
-	fmt.Println("> Scanning directory:", fileutils.GetAbsolutePath(repository))
+	output.Println("> Scanning directory:", fileutils.GetAbsolutePath(repository))
 
 	if dockerAccessKey, err := docker.GetPrivadoDockerAccessKey(true); err != nil || dockerAccessKey == "" {
 		exit(fmt.Sprintf("Cannot fetch docker access key: %v \nPlease try again or raise an issue at %s", err, config.AppConfig.PrivadoRepository), true)
@@ -225,6 +236,9 @@ func scan(cmd *cobra.Command, args []string) {
 		commandArgs = append(commandArgs, "--monolith")
 	}
 
+	// results written before this point are from a previous scan
+	scanStartTime := time.Now()
+
 	// run image with options
 	err = docker.RunImage(
 		docker.OptionWithLatestImage(false), // because we already pull the image for access-key (with pullImage parameter)
@@ -246,21 +260,37 @@ func scan(cmd *cobra.Command, args []string) {
 			{Key: "PRIVADO_HOST_SCAN_DIR", Value: fileutils.GetAbsolutePath(repository)},
 			{Key: "PRIVADO_USER_HASH", Value: config.UserConfig.UserHash},
 			{Key: "PRIVADO_SESSION_ID", Value: config.UserConfig.SessionId},
-			{Key: "PRIVADO_SYNC_TO_CLOUD", Value: strings.ToUpper(strconv.FormatBool(config.UserConfig.ConfigFile.SyncToPrivadoCloud))},
-			{Key: "PRIVADO_METRICS_ENABLED", Value: strings.ToUpper(strconv.FormatBool(config.UserConfig.ConfigFile.MetricsEnabled))},
+			{Key: "PRIVADO_SYNC_TO_CLOUD", Value: strings.ToUpper(strconv.FormatBool(config.UserConfig.EffectiveConfig.SyncToPrivadoCloud))},
+			{Key: config.MetricsEnabledEnvKey, Value: strings.ToUpper(strconv.FormatBool(config.IsMetricsEnabled()))},
 			{Key: "JAVA_TOOL_OPTIONS", Value: jvmArgs},
 		}),
 		docker.OptionWithAutoSpawnBrowserOnURLMessages([]string{
 			"> Continue to view results on:",
 		}),
+		docker.OptionWithDisabledBrowser(isBrowserDisabled(cmd)),
+		docker.OptionWithDashboardURLFile(dashboardURLFile),
+		docker.OptionWithProxy(getProxyConfiguration(cmd)),
+		docker.OptionWithCABundle(getContainerCertificatesDirectory(cmd)),
+		docker.OptionWithProgress(),
+		docker.OptionWithErrorClassification(loadEngineErrorCatalogue()),
 		docker.OptionWithInterrupt(),
 	)
 	if err != nil {
-		exit(fmt.Sprintf("Received error: %s", err), true)
+		exitOnRunImageError(err)
+	}
+
+	resultsPath := filepath.Join(fileutils.GetAbsolutePath(repository), config.AppConfig.PrivacyResultsPathSuffix)
+	// only report results written by this scan, not existing results (the
+	// start time is truncated for filesystems with a coarse modification time)
+	if info, err := os.Stat(resultsPath); err == nil && !info.ModTime().Before(scanStartTime.Truncate(time.Second)) {
+		output.Emit(output.Event{Event: output.EventResult, Path: resultsPath})
 	}
 }
 
 func init() {
 	defineScanFlags(scanCmd)
+	defineImageCommandFlags(scanCmd)
+	defineDashboardFlags(scanCmd)
+	defineNetworkFlags(scanCmd)
 	rootCmd.AddCommand(scanCmd)
 }
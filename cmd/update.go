@@ -24,6 +24,7 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -33,6 +34,8 @@ import (
 
 	"github.com/Privado-Inc/privado-cli/pkg/config"
 	"github.com/Privado-Inc/privado-cli/pkg/fileutils"
+	"github.com/Privado-Inc/privado-cli/pkg/signature"
+	"github.com/Privado-Inc/privado-cli/pkg/telemetry"
 	"github.com/Privado-Inc/privado-cli/pkg/utils"
 	"github.com/spf13/cobra"
 	"golang.org/x/mod/semver"
@@ -41,21 +44,31 @@ import (
 var updateCmd = &cobra.Command{
 	Use:   "update",
 	Short: "Check for latest release and update to the latest version Privado CLI",
-	Long:  "Check for latest release and update to the latest version Privado CLI",
+	Long:  "Check for latest release and update to the latest version Privado CLI\nUse --version to install a specific release (including downgrades), and --rollback to restore the previously installed version\n\nRelease assets are verified with their signed checksums before installation. Releases published before release assets were signed cannot be verified: to install them with --version (e.g. to downgrade to a known-good release), use --allow-unsigned",
 	Args:  cobra.ExactArgs(0),
 	Run:   update,
 }
 
+// the check is recorded as a span of the run (for the commands that export traces)
 func checkForUpdate() (hasUpdate bool, updateMessage string, err error) {
+	updateCheckSpan := telemetry.StartSpan("update.check")
+	hasUpdate, updateMessage, _, err = checkForLatestRelease()
+	updateCheckSpan.Finish(err)
+	return hasUpdate, updateMessage, err
+}
+
+// similar to checkForUpdate, also returns the tag of the latest release
+func checkForLatestRelease() (hasUpdate bool, updateMessage string, releaseTag string, err error) {
 	if Version == "dev" {
-		return false, "", nil
+		return false, "", "", nil
 	}
 
-	// get release info (nil when not available)
-	releaseInfo, err := utils.GetLatestReleaseFromGitHub(config.AppConfig.PrivadoRepositoryName)
+	// get release info for the channel (nil when not available)
+	channel, _ := config.GetChannel()
+	releaseInfo, err := utils.GetLatestReleaseFromGitHub(config.AppConfig.PrivadoRepositoryName, channel)
 
 	if err != nil || releaseInfo == nil || releaseInfo.TagName == "" || releaseInfo.PublishedAt == "" {
-		return false, "", err
+		return false, "", "", err
 	}
 
 	// compare release, -1, 0, 1
@@ -77,12 +90,19 @@ func checkForUpdate() (hasUpdate bool, updateMessage string, err error) {
 			}
 			updateMessage = fmt.Sprintf("New release found: %s (%s)", releaseInfo.TagName, daySinceString)
 		}
+		if channel != config.ChannelStable {
+			updateMessage = fmt.Sprintf("%s [%s channel]", strings.TrimSuffix(updateMessage, "\n"), channel)
+		}
 	}
 
-	return hasUpdate, updateMessage, nil
+	return hasUpdate, updateMessage, releaseInfo.TagName, nil
 }
 
 func update(cmd *cobra.Command, args []string) {
+	targetVersion, _ := cmd.Flags().GetString("version")
+	rollback, _ := cmd.Flags().GetBool("rollback")
+	allowUnsigned, _ := cmd.Flags().GetBool("allow-unsigned")
+
 	version(cmd, args)
 	fmt.Println()
 	time.Sleep(config.AppConfig.SlowdownTime)
@@ -111,25 +131,72 @@ func update(cmd *cobra.Command, args []string) {
 		exit("Try again with a privileged user (sudo)?", true)
 	}
 
-	// check for release info
-	fmt.Println("Fetching latest release..")
-	hasUpdate, updateMessage, err := checkForUpdate()
-	if err != nil {
-		exitUpdate("Could not fetch latest release. Some error occurred", true)
+	if rollback {
+		rollbackUpdate(currentExecPath)
+		return
 	}
-	if !hasUpdate {
-		exit(fmt.Sprint("You are already using the latest version of Privado CLI: ", Version), false)
+	if allowUnsigned && targetVersion == "" {
+		exit("--allow-unsigned can only be used with --version", true)
+	}
+
+	// check for release info
+	var releaseTag string
+	var isUnverified bool
+	if targetVersion == "" {
+		fmt.Println("Fetching latest release..")
+		hasUpdate, updateMessage, latestReleaseTag, err := checkForLatestRelease()
+		if err != nil {
+			exitUpdate("Could not fetch latest release. Some error occurred", true)
+		}
+		if !hasUpdate {
+			exit(fmt.Sprint("You are already using the latest version of Privado CLI: ", Version), false)
+		}
+		fmt.Println(updateMessage)
+		releaseTag = latestReleaseTag
+	} else {
+		releaseTag = getReleaseTagForVersion(targetVersion)
+		if !semver.IsValid(releaseTag) {
+			exitUpdate(fmt.Sprintf("Invalid version: %s (e.g. v1.2.3)", targetVersion), true)
+		}
+		if semver.Compare(releaseTag, Version) == 0 {
+			exit(fmt.Sprint("You are already using this version of Privado CLI: ", Version), false)
+		}
+
+		fmt.Printf("Fetching release %s..\n", releaseTag)
+		releaseInfo, err := utils.GetReleaseByTagFromGitHub(config.AppConfig.PrivadoRepositoryName, releaseTag)
+		if err != nil {
+			exitUpdate(fmt.Sprintf("Could not fetch release %s. Some error occurred", releaseTag), true)
+		}
+		if releaseInfo == nil {
+			exitUpdate(fmt.Sprintf("Could not find release %s\nFor available releases, visit %s/releases", releaseTag, config.AppConfig.PrivadoRepository), true)
+		}
+		// releases before signing have no (signed) checksum manifest, and
+		// cannot be verified: these are only installed with --allow-unsigned
+		isUnsigned := !releaseInfo.HasAsset(config.ExtConfig.GitHubReleaseChecksumsFilename) ||
+			(signature.HasReleaseKeys() && !releaseInfo.HasAsset(config.ExtConfig.GitHubReleaseChecksumsFilename+config.ExtConfig.GitHubReleaseSignatureExtension))
+		if isUnsigned {
+			if !allowUnsigned {
+				exitUpdate(fmt.Sprintf("Release %s is not signed, and cannot be verified for installation\nTo install it without verification, use --allow-unsigned (only for releases you trust)", releaseTag), true)
+			}
+			fmt.Println()
+			fmt.Printf("[WARN]: Release %s is not signed. It is installed WITHOUT verifying its integrity (--allow-unsigned)\n", releaseTag)
+			fmt.Println()
+			isUnverified = true
+		}
+
+		if semver.Compare(releaseTag, Version) < 0 {
+			fmt.Printf("Downgrading Privado CLI: %s -> %s\n", Version, releaseTag)
+		} else {
+			fmt.Printf("Updating Privado CLI: %s -> %s\n", Version, releaseTag)
+		}
 	}
-	fmt.Println(updateMessage)
 	time.Sleep(config.AppConfig.SlowdownTime)
 
-	// get download url
-	replacer := strings.NewReplacer(
-		"${REPO_NAME}", config.AppConfig.PrivadoRepositoryName,
-		"${REPO_TAG}", "latest",
-		"${REPO_RELEASE_FILE}", config.AppConfig.PrivadoRepositoryReleaseFilename,
-	)
-	githubReleaseDownloadURL := replacer.Replace(config.ExtConfig.GitHubReleaseDownloadURL)
+	// get download urls
+	githubReleaseDownloadURL := getReleaseAssetURL(releaseTag, config.AppConfig.PrivadoRepositoryReleaseFilename)
+	githubReleaseChecksumsURL := getReleaseAssetURL(releaseTag, config.ExtConfig.GitHubReleaseChecksumsFilename)
+	githubReleaseSignatureURL := getReleaseAssetURL(releaseTag, config.AppConfig.PrivadoRepositoryReleaseFilename+config.ExtConfig.GitHubReleaseSignatureExtension)
+	githubReleaseKeysURL := getReleaseAssetURL(releaseTag, config.ExtConfig.GitHubReleaseKeysFilename)
 
 	// create temporary directory for update assets
 	// another approach is to use installation dir to create temp dir instead of systemm default
@@ -156,6 +223,15 @@ func update(cmd *cobra.Command, args []string) {
 	fmt.Println("Downloaded release asset:", githubReleaseDownloadURL)
 	time.Sleep(config.AppConfig.SlowdownTime)
 
+	// verify the integrity of the release asset before extraction
+	fmt.Println()
+	if isUnverified {
+		fmt.Println("[WARN]: Skipping verification of the unsigned release asset (--allow-unsigned)")
+	} else {
+		verifyReleaseAsset(downloadedFilePath, releaseTag, githubReleaseChecksumsURL, githubReleaseSignatureURL, githubReleaseKeysURL)
+	}
+	time.Sleep(config.AppConfig.SlowdownTime)
+
 	// extract .tar.gz
 	fmt.Println()
 	fmt.Println("Extracting release asset..")
@@ -168,8 +244,15 @@ func update(cmd *cobra.Command, args []string) {
 	time.Sleep(config.AppConfig.SlowdownTime)
 	fmt.Println()
 
+	// keep the current version, to restore it with `privado update --rollback`
+	if err := saveUpdateBackup(currentExecPath, Version); err != nil {
+		fmt.Println("> Warning: Could not keep a backup of the current version for rollback:", err)
+	} else {
+		fmt.Printf("> Kept a backup of the current version (%s) for rollback\n", Version)
+	}
+
 	// Replace existing binary (in current execution) by the updated binary
-	fmt.Println("Installing latest release..")
+	fmt.Printf("Installing release %s..\n", releaseTag)
 	time.Sleep(config.AppConfig.SlowdownTime)
 	err = fileutils.SafeMoveFile(filepath.Join(temporaryDirectory, "privado"), currentExecPath, true)
 	if err != nil {
@@ -179,10 +262,197 @@ func update(cmd *cobra.Command, args []string) {
 	// woof! all done.
 	time.Sleep(config.AppConfig.SlowdownTime)
 	fmt.Println()
-	fmt.Println("Installed latest release!")
+	fmt.Printf("Installed release %s!\n", releaseTag)
+	fmt.Println("To validate installation, run `privado version`")
+	fmt.Println("To restore the previous version, run `privado update --rollback`")
+}
+
+// restores the backup of the previously installed version, and keeps the
+// current version as backup (so that a rollback can be undone)
+func rollbackUpdate(currentExecPath string) {
+	backupBinaryPath, backupVersion, err := getUpdateBackup()
+	if err != nil {
+		exitUpdate(fmt.Sprint("No previous version to roll back to: ", err), true)
+	}
+	fmt.Printf("Rolling back Privado CLI: %s -> %s..\n", Version, backupVersion)
+	time.Sleep(config.AppConfig.SlowdownTime)
+
+	temporaryDirectory, err := ioutil.TempDir("", "privado-rollback-")
+	if err != nil {
+		exitUpdate("Could not create temporary directory. Terminating..", true)
+	}
+	defer os.RemoveAll(temporaryDirectory)
+
+	// the backup is replaced below, install from a copy
+	rollbackBinaryPath := filepath.Join(temporaryDirectory, "privado")
+	if err := fileutils.CopyFile(backupBinaryPath, rollbackBinaryPath); err != nil {
+		exitUpdate(fmt.Sprint("Could not read the backup: ", err), true)
+	}
+
+	if err := saveUpdateBackup(currentExecPath, Version); err != nil {
+		fmt.Println("> Warning: Could not keep a backup of the current version:", err)
+	}
+
+	if err := fileutils.SafeMoveFile(rollbackBinaryPath, currentExecPath, true); err != nil {
+		exitUpdate(fmt.Sprint("Could not restore the previous version: ", err), true)
+	}
+
+	time.Sleep(config.AppConfig.SlowdownTime)
+	fmt.Println()
+	fmt.Printf("Restored version %s!\n", backupVersion)
 	fmt.Println("To validate installation, run `privado version`")
 }
 
+// copies the binary (and its version) to the update backup directory
+func saveUpdateBackup(binaryPath, binaryVersion string) error {
+	if err := os.MkdirAll(config.AppConfig.UpdateBackupDirectory, os.ModePerm); err != nil {
+		return err
+	}
+
+	// copy to a temporary file first, to not leave a partial backup
+	backupBinaryPath, backupVersionPath := getUpdateBackupPaths()
+	if err := fileutils.CopyFile(binaryPath, backupBinaryPath+".tmp"); err != nil {
+		os.Remove(backupBinaryPath + ".tmp")
+		return err
+	}
+	if err := os.Rename(backupBinaryPath+".tmp", backupBinaryPath); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(backupVersionPath, []byte(binaryVersion), 0644)
+}
+
+func getUpdateBackup() (backupBinaryPath string, backupVersion string, err error) {
+	backupBinaryPath, backupVersionPath := getUpdateBackupPaths()
+	if exists, _ := fileutils.DoesFileExists(backupBinaryPath); !exists {
+		return "", "", fmt.Errorf("no backup found (%s)", config.AppConfig.UpdateBackupDirectory)
+	}
+
+	versionData, err := ioutil.ReadFile(backupVersionPath)
+	if err != nil {
+		return "", "", err
+	}
+	return backupBinaryPath, strings.TrimSpace(string(versionData)), nil
+}
+
+func getUpdateBackupPaths() (backupBinaryPath string, backupVersionPath string) {
+	return filepath.Join(config.AppConfig.UpdateBackupDirectory, "privado"), filepath.Join(config.AppConfig.UpdateBackupDirectory, "version")
+}
+
+// release tags are prefixed with 'v' (e.g. v1.2.3)
+func getReleaseTagForVersion(version string) string {
+	if !strings.HasPrefix(version, "v") {
+		return "v" + version
+	}
+	return version
+}
+
+func getReleaseAssetURL(tag, filename string) string {
+	replacer := strings.NewReplacer(
+		"${REPO_NAME}", config.AppConfig.PrivadoRepositoryName,
+		"${REPO_TAG}", tag,
+		"${REPO_RELEASE_FILE}", filename,
+	)
+	return replacer.Replace(config.ExtConfig.GitHubReleaseDownloadURL)
+}
+
+// verifies the integrity of the release asset before extraction: with the
+// signed checksum manifest, and the signature of the asset (for this release)
+// exits the update when the release asset cannot be verified
+func verifyReleaseAsset(assetPath, releaseTag, checksumsURL, signatureURL, keysURL string) {
+	fmt.Println("Verifying release asset..")
+	// signatures are verified once release keys are embedded in the build
+	var trustedKeys []signature.PublicKey
+	if signature.HasReleaseKeys() {
+		var err error
+		trustedKeys, err = getTrustedKeysFromURL(keysURL)
+		if err != nil {
+			exitUpdate(fmt.Sprint("Could not verify release asset: ", err), true)
+		}
+	} else {
+		fmt.Println("[WARN]: This build has no embedded release keys, the signatures of the release are not verified")
+	}
+
+	checksum, err := verifyReleaseAssetChecksum(assetPath, releaseTag, checksumsURL, trustedKeys)
+	if err != nil {
+		exitUpdate(fmt.Sprint("Could not verify release asset: ", err), true)
+	}
+	fmt.Println("Verified SHA-256:", checksum)
+
+	if trustedKeys != nil {
+		key, err := verifyReleaseAssetSignatureFromURL(assetPath, releaseTag, signatureURL, trustedKeys)
+		if err != nil {
+			exitUpdate(fmt.Sprint("Could not verify the signature of release asset: ", err, "\nThe release asset is not installed"), true)
+		}
+		fmt.Println("Verified signature: key id", key.KeyId)
+	}
+}
+
+// compares the SHA-256 digest of the release asset with the checksum manifest
+// of the release (verified with its signature, unless trustedKeys is nil),
+// and returns the verified digest
+func verifyReleaseAssetChecksum(assetPath, releaseTag, checksumsURL string, trustedKeys []signature.PublicKey) (string, error) {
+	manifest, err := utils.DownloadToMemory(checksumsURL)
+	if err != nil {
+		return "", fmt.Errorf("checksum manifest: %w", err)
+	}
+
+	if trustedKeys != nil {
+		manifestSignatureData, err := utils.DownloadToMemory(checksumsURL + config.ExtConfig.GitHubReleaseSignatureExtension)
+		if err != nil {
+			return "", fmt.Errorf("checksum manifest is not signed: %w", err)
+		}
+
+		manifestSignature, err := signature.ParseSignature(manifestSignatureData)
+		if err != nil {
+			return "", fmt.Errorf("checksum manifest signature: %w", err)
+		}
+		if _, err := manifestSignature.VerifyReleaseAsset(bytes.NewReader(manifest), trustedKeys, releaseTag, config.ExtConfig.GitHubReleaseChecksumsFilename); err != nil {
+			return "", fmt.Errorf("checksum manifest: %w", err)
+		}
+	}
+
+	expectedChecksum, err := utils.GetChecksumFromManifest(manifest, filepath.Base(assetPath))
+	if err != nil {
+		return "", err
+	}
+
+	checksum, err := fileutils.GetSHA256ChecksumOfFile(assetPath)
+	if err != nil {
+		return "", err
+	}
+
+	if checksum != expectedChecksum {
+		return "", fmt.Errorf("SHA-256 mismatch: expected %s, got %s. The release asset is not installed", expectedChecksum, checksum)
+	}
+	return checksum, nil
+}
+
+// fetches the signed key list of the release (optional, for rotated keys),
+// and returns the keys trusted for the release assets
+func getTrustedKeysFromURL(keyListURL string) ([]signature.PublicKey, error) {
+	// a missing key list only limits the trusted keys to the embedded release keys
+	var keyListSignature []byte
+	keyList, err := utils.DownloadToMemory(keyListURL)
+	if err != nil {
+		keyList = nil
+	} else if keyListSignature, err = utils.DownloadToMemory(keyListURL + config.ExtConfig.GitHubReleaseSignatureExtension); err != nil {
+		return nil, fmt.Errorf("key list is not signed: %w", err)
+	}
+
+	return signature.GetTrustedKeys(keyList, keyListSignature)
+}
+
+// fetches the signature of the release asset to verify the release asset
+func verifyReleaseAssetSignatureFromURL(assetPath, releaseTag, signatureURL string, trustedKeys []signature.PublicKey) (*signature.PublicKey, error) {
+	signatureData, err := utils.DownloadToMemory(signatureURL)
+	if err != nil {
+		return nil, fmt.Errorf("release asset is not signed: %w", err)
+	}
+
+	key, _, err := verifyReleaseAssetSignature(assetPath, releaseTag, signatureData, trustedKeys)
+	return key, err
+}
+
 func exitUpdate(msg string, isError bool) {
 	fmt.Println(msg)
 	fmt.Println()
@@ -190,5 +460,9 @@ func exitUpdate(msg string, isError bool) {
 }
 
 func init() {
+	updateCmd.Flags().String("version", "", "Install a specific release (e.g. v1.2.3), including older releases")
+	updateCmd.Flags().Bool("rollback", false, "Restore the version installed before the last update (or rollback)")
+	updateCmd.Flags().Bool("allow-unsigned", false, "With --version: install a release published before releases were signed, WITHOUT verifying its integrity. Use only for releases you trust")
+	updateCmd.MarkFlagsMutuallyExclusive("version", "rollback")
 	rootCmd.AddCommand(updateCmd)
 }
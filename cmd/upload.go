@@ -34,36 +34,38 @@ import (
 	"github.com/Privado-Inc/privado-cli/pkg/config"
 	"github.com/Privado-Inc/privado-cli/pkg/docker"
 	"github.com/Privado-Inc/privado-cli/pkg/fileutils"
+	"github.com/Privado-Inc/privado-cli/pkg/output"
+	"github.com/Privado-Inc/privado-cli/pkg/telemetry"
 	"github.com/spf13/cobra"
 )
 
 var uploadCmd = &cobra.Command{
-	Use:   "upload <repository>",
-	Short: "Sync scan results with Privado Dashboard",
-	Args:  cobra.ExactArgs(1),
-	PreRun: func(cmd *cobra.Command, args []string) {
-		telemetryPreRun(nil)
-	},
-	Run: upload,
-	PostRun: func(cmd *cobra.Command, args []string) {
-		telemetryPostRun(nil)
-	},
+	Use:     "upload <repository>",
+	Short:   "Sync scan results with Privado Dashboard",
+	Args:    cobra.ExactArgs(1),
+	PreRun:  imageCommandPreRun,
+	Run:     upload,
+	PostRun: imageCommandPostRun,
 }
 
 func upload(cmd *cobra.Command, args []string) {
 	repository := args[0]
 	debug, _ := cmd.Flags().GetBool("debug")
+	dashboardURLFile, _ := cmd.Flags().GetString("dashboard-url-file")
+	if dashboardURLFile != "" {
+		dashboardURLFile = fileutils.GetAbsolutePath(dashboardURLFile)
+	}
 
 	hasUpdate, updateMessage, err := checkForUpdate()
 	if err == nil && hasUpdate {
-		fmt.Println(updateMessage)
+		output.Println(updateMessage)
 		time.Sleep(config.AppConfig.SlowdownTime)
-		fmt.Println("To use the latest version of Privado CLI, run `privado update`")
+		output.Println("To use the latest version of Privado CLI, run `privado update`")
 		time.Sleep(config.AppConfig.SlowdownTime)
-		fmt.Println()
+		output.Println()
 	}
 
-	fmt.Println("> Uploading results for directory:", fileutils.GetAbsolutePath(repository))
+	output.Println("> Uploading results for directory:", fileutils.GetAbsolutePath(repository))
 	time.Sleep(config.AppConfig.SlowdownTime)
 
 	resultsPath := filepath.Join(fileutils.GetAbsolutePath(repository), config.AppConfig.PrivacyResultsPathSuffix)
@@ -89,6 +91,7 @@ func upload(cmd *cobra.Command, args []string) {
 	commandArgs := []string{config.AppConfig.Container.SourceCodeVolumeDir}
 
 	// run image with options
+	uploadSpan := telemetry.StartSpan("upload")
 	err = docker.RunImage(
 		docker.OptionWithLatestImage(false), // because we already pull the image for access-key (with pullImage parameter)
 		docker.OptionWithEntrypoint(command),
@@ -103,19 +106,28 @@ func upload(cmd *cobra.Command, args []string) {
 			{Key: "PRIVADO_HOST_SCAN_DIR", Value: fileutils.GetAbsolutePath(repository)},
 			{Key: "PRIVADO_USER_HASH", Value: config.UserConfig.UserHash},
 			{Key: "PRIVADO_SESSION_ID", Value: config.UserConfig.SessionId},
-			{Key: "PRIVADO_SYNC_TO_CLOUD", Value: strings.ToUpper(strconv.FormatBool(config.UserConfig.ConfigFile.SyncToPrivadoCloud))},
-			{Key: "PRIVADO_METRICS_ENABLED", Value: strings.ToUpper(strconv.FormatBool(config.UserConfig.ConfigFile.MetricsEnabled))},
+			{Key: "PRIVADO_SYNC_TO_CLOUD", Value: strings.ToUpper(strconv.FormatBool(config.UserConfig.EffectiveConfig.SyncToPrivadoCloud))},
+			{Key: config.MetricsEnabledEnvKey, Value: strings.ToUpper(strconv.FormatBool(config.IsMetricsEnabled()))},
 		}),
 		docker.OptionWithAutoSpawnBrowserOnURLMessages([]string{
 			"> Continue to view results on:",
 		}),
+		docker.OptionWithDisabledBrowser(isBrowserDisabled(cmd)),
+		docker.OptionWithDashboardURLFile(dashboardURLFile),
+		docker.OptionWithProxy(getProxyConfiguration(cmd)),
+		docker.OptionWithCABundle(getContainerCertificatesDirectory(cmd)),
+		docker.OptionWithErrorClassification(loadEngineErrorCatalogue()),
 		docker.OptionWithInterrupt(),
 	)
+	uploadSpan.Finish(err)
 	if err != nil {
-		exit(fmt.Sprintf("Received error: %s", err), true)
+		exitOnRunImageError(err)
 	}
 }
 
 func init() {
+	defineImageCommandFlags(uploadCmd)
+	defineDashboardFlags(uploadCmd)
+	defineNetworkFlags(uploadCmd)
 	rootCmd.AddCommand(uploadCmd)
 }
@@ -33,20 +33,17 @@ import (
 	"github.com/Privado-Inc/privado-cli/pkg/config"
 	"github.com/Privado-Inc/privado-cli/pkg/docker"
 	"github.com/Privado-Inc/privado-cli/pkg/fileutils"
+	"github.com/Privado-Inc/privado-cli/pkg/output"
 	"github.com/spf13/cobra"
 )
 
 var validateCmd = &cobra.Command{
-	Use:   "validate <rules-directory>",
-	Short: "Validate rule structure for custome rules",
-	Args:  cobra.ExactArgs(1),
-	PreRun: func(cmd *cobra.Command, args []string) {
-		telemetryPreRun(nil)
-	},
-	Run: validate,
-	PostRun: func(cmd *cobra.Command, args []string) {
-		telemetryPostRun(nil)
-	},
+	Use:     "validate <rules-directory>",
+	Short:   "Validate rule structure for custome rules",
+	Args:    cobra.ExactArgs(1),
+	PreRun:  imageCommandPreRun,
+	Run:     validate,
+	PostRun: imageCommandPostRun,
 }
 
 func validate(cmd *cobra.Command, args []string) {
@@ -54,14 +51,14 @@ func validate(cmd *cobra.Command, args []string) {
 
 	hasUpdate, updateMessage, err := checkForUpdate()
 	if err == nil && hasUpdate {
-		fmt.Println(updateMessage)
+		output.Println(updateMessage)
 		time.Sleep(config.AppConfig.SlowdownTime)
-		fmt.Println("To use the latest version of Privado CLI, run `privado update`")
+		output.Println("To use the latest version of Privado CLI, run `privado update`")
 		time.Sleep(config.AppConfig.SlowdownTime)
-		fmt.Println()
+		output.Println()
 	}
 
-	fmt.Println("> Validating rules for the directory: ", fileutils.GetAbsolutePath(externalRules))
+	output.Println("> Validating rules for the directory: ", fileutils.GetAbsolutePath(externalRules))
 	time.Sleep(config.AppConfig.SlowdownTime)
 
 	if exists, _ := fileutils.DoesFileExists(externalRules); !exists {
@@ -101,19 +98,21 @@ func validate(cmd *cobra.Command, args []string) {
 			// {Key: "PRIVADO_HOST_SCAN_DIR", Value: fileutils.GetAbsolutePath(repository)},
 			{Key: "PRIVADO_USER_HASH", Value: config.UserConfig.UserHash},
 			{Key: "PRIVADO_SESSION_ID", Value: config.UserConfig.SessionId},
-			{Key: "PRIVADO_SYNC_TO_CLOUD", Value: strings.ToUpper(strconv.FormatBool(config.UserConfig.ConfigFile.SyncToPrivadoCloud))},
-			{Key: "PRIVADO_METRICS_ENABLED", Value: strings.ToUpper(strconv.FormatBool(config.UserConfig.ConfigFile.MetricsEnabled))},
+			{Key: "PRIVADO_SYNC_TO_CLOUD", Value: strings.ToUpper(strconv.FormatBool(config.UserConfig.EffectiveConfig.SyncToPrivadoCloud))},
+			{Key: config.MetricsEnabledEnvKey, Value: strings.ToUpper(strconv.FormatBool(config.IsMetricsEnabled()))},
 		}),
+		docker.OptionWithErrorClassification(loadEngineErrorCatalogue()),
 		docker.OptionWithInterrupt(),
 	)
 
 	time.Sleep(config.AppConfig.SlowdownTime)
 
 	if err != nil {
-		exit(fmt.Sprintf("Received error: %s", err), true)
+		exitOnRunImageError(err)
 	}
 }
 
 func init() {
+	defineImageCommandFlags(validateCmd)
 	rootCmd.AddCommand(validateCmd)
 }
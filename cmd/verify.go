@@ -0,0 +1,126 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ *
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Privado-Inc/privado-cli/pkg/config"
+	"github.com/Privado-Inc/privado-cli/pkg/fileutils"
+	"github.com/Privado-Inc/privado-cli/pkg/signature"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify <archive> --version <release>",
+	Short: "Verify the signature of a Privado CLI release archive",
+	Long:  "Verify the detached (minisign) signature of a Privado CLI release archive with the release keys compiled into Privado CLI\nThe signature must be for the release (--version) and the archive file name, as published",
+	Args:  cobra.ExactArgs(1),
+	Run:   verify,
+}
+
+func verify(cmd *cobra.Command, args []string) {
+	archivePath := fileutils.GetAbsolutePath(args[0])
+	signaturePath, _ := cmd.Flags().GetString("signature")
+	keyListPath, _ := cmd.Flags().GetString("keys")
+	releaseVersion, _ := cmd.Flags().GetString("version")
+	releaseTag := getReleaseTagForVersion(releaseVersion)
+
+	if signaturePath == "" {
+		signaturePath = archivePath + config.ExtConfig.GitHubReleaseSignatureExtension
+	}
+
+	if exists, _ := fileutils.DoesFileExists(archivePath); !exists {
+		exit(fmt.Sprint("Cannot find the archive: ", archivePath), true)
+	}
+
+	signatureData, err := ioutil.ReadFile(signaturePath)
+	if err != nil {
+		exit(fmt.Sprintf("Cannot read the signature (%s): %s\nUse --signature to specify the signature file", signaturePath, err), true)
+	}
+
+	// the key list is optional, but must be signed when provided
+	var keyList, keyListSignature []byte
+	if keyListPath != "" {
+		if keyList, err = ioutil.ReadFile(keyListPath); err != nil {
+			exit(fmt.Sprintf("Cannot read the key list (%s): %s", keyListPath, err), true)
+		}
+		keyListSignaturePath := keyListPath + config.ExtConfig.GitHubReleaseSignatureExtension
+		if keyListSignature, err = ioutil.ReadFile(keyListSignaturePath); err != nil {
+			exit(fmt.Sprintf("Cannot read the key list signature (%s): %s", keyListSignaturePath, err), true)
+		}
+	}
+
+	trustedKeys, err := signature.GetTrustedKeys(keyList, keyListSignature)
+	if err != nil {
+		exit(fmt.Sprintf("Verification failed for %s: %s", filepath.Base(archivePath), err), true)
+	}
+
+	key, sig, err := verifyReleaseAssetSignature(archivePath, releaseTag, signatureData, trustedKeys)
+	if err != nil {
+		exit(fmt.Sprintf("Verification failed for %s: %s", filepath.Base(archivePath), err), true)
+	}
+
+	checksum, err := fileutils.GetSHA256ChecksumOfFile(archivePath)
+	if err != nil {
+		exit(fmt.Sprint("Could not compute SHA-256 of the archive: ", err), true)
+	}
+
+	fmt.Println("Verified signature:", filepath.Base(archivePath))
+	fmt.Println("Key id:", key.KeyId)
+	fmt.Println("Trusted comment:", sig.TrustedComment)
+	fmt.Println("SHA-256:", checksum)
+}
+
+// verifies the signature of a release asset with the trusted keys (see
+// signature.GetTrustedKeys), for the release and the file name of the asset
+func verifyReleaseAssetSignature(assetPath, releaseTag string, signatureData []byte, trustedKeys []signature.PublicKey) (*signature.PublicKey, *signature.Signature, error) {
+	sig, err := signature.ParseSignature(signatureData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	asset, err := os.Open(assetPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer asset.Close()
+
+	key, err := sig.VerifyReleaseAsset(asset, trustedKeys, releaseTag, filepath.Base(assetPath))
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, sig, nil
+}
+
+func init() {
+	verifyCmd.Flags().String("version", "", "Release of the archive (e.g. v1.2.3), as signed")
+	verifyCmd.MarkFlagRequired("version")
+	verifyCmd.Flags().String("signature", "", "Path to the signature file (default: <archive>.minisig)")
+	verifyCmd.Flags().String("keys", "", "Path to a signed key list with rotated release keys (signature: <keys>.minisig)")
+	rootCmd.AddCommand(verifyCmd)
+}
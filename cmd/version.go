@@ -46,6 +46,9 @@ func version(cmd *cobra.Command, args []string) {
 		printVersion = "Nightly"
 	}
 	fmt.Printf("Privado CLI: Version %s (%s-%s) \n", printVersion, runtime.GOOS, runtime.GOARCH)
+	if channel, _ := config.GetChannel(); channel != config.ChannelStable {
+		fmt.Printf("Release channel: %s (image: %s)\n", channel, config.AppConfig.Container.ImageURL)
+	}
 
 	// Additional info for exclusively this cmd (so 'version' can be called just to print version)
 	if cmd.Name() == "version" {
@@ -25,6 +25,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/Privado-Inc/privado-cli/cmd"
 	"github.com/Privado-Inc/privado-cli/pkg/auth"
@@ -36,22 +37,44 @@ func bootstrap() {
 	// bootstrap to populate ci session details from env in the ci package
 	ci.Bootstrap(config.AppConfig.CIUserIdentifierEnvKey)
 
+	// the configuration directory may not be writable (e.g. read-only or ephemeral
+	// home directories in CI): continue with a temporary directory instead
+	if err := bootstrapConfigurationDirectory(); err != nil {
+		configurationDirectory := config.AppConfig.ConfigurationDirectory
+		temporaryHome, tempErr := config.UseTemporaryPrivadoHome()
+		if tempErr != nil {
+			panic(fmt.Sprintf("Fatal: %s", err))
+		}
+		fmt.Fprintf(os.Stderr, "[WARN]: Cannot use %s (%s)\n", configurationDirectory, err)
+		fmt.Fprintf(os.Stderr, "[WARN]: Using a temporary directory instead: %s. To persist configuration, set %s to a writable directory\n", temporaryHome, config.PrivadoHomeEnvKey)
+
+		if err := bootstrapConfigurationDirectory(); err != nil {
+			panic(fmt.Sprintf("Fatal: %s", err))
+		}
+	}
+
+	// invalid configuration is not fatal, warnings are printed to stderr
+	// so they do not interfere with the output of commands (e.g. json)
+	for _, warning := range config.LoadUserConfiguration() {
+		fmt.Fprintln(os.Stderr, "[WARN]:", warning)
+	}
+}
+
+func bootstrapConfigurationDirectory() error {
 	// bootstrap the userkey UUID
 	// Any existing "user.key" will override the identified CIUserIdentifier in the previous step
 	// Existing key takes precendence. This is intentional as CI users also may want to bootstrap
 	// their environment with an existing key, in which case also the set env var is ignored
-	err := auth.BootstrapUserKey(config.AppConfig.UserKeyPath, config.AppConfig.UserKeyDirectory)
-	if err != nil {
-		panic(fmt.Sprintf("Fatal: cannot bootstrap user key: %s", err))
+	if err := auth.BootstrapUserKey(config.AppConfig.UserKeyPath, config.AppConfig.UserKeyDirectory); err != nil {
+		return fmt.Errorf("cannot bootstrap user key: %w", err)
 	}
 
 	// bootstrap the configuration file
-	err = config.BootstrapUserConfiguration(false)
-	if err != nil {
-		panic(fmt.Sprintf("Fatal: cannot bootstrap user configuration: %s", err))
+	if err := config.BootstrapUserConfiguration(false); err != nil {
+		return fmt.Errorf("cannot bootstrap user configuration: %w", err)
 	}
 
-	config.LoadUserConfiguration()
+	return nil
 }
 
 func main() {
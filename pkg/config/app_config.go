@@ -27,7 +27,6 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
-	"strconv"
 	"strings"
 	"time"
 
@@ -37,17 +36,26 @@ import (
 
 var AppConfig *Configuration
 
+const defaultImageRepository = "public.ecr.aws/privado/privado"
+
 type Configuration struct {
 	HomeDirectory                    string
 	CacheDirectory                   string
+	StateDirectory                   string
 	ConfigurationDirectory           string
 	UserConfigurationFilePath        string
 	UserKeyDirectory                 string
 	UserKeyPath                      string
+	ErrorCatalogueFilePath           string
+	TelemetryPreviewFilePath         string
+	TelemetryPreviewRequestFilePath  string
+	TelemetryQueueDirectory          string
+	UpdateBackupDirectory            string
 	CIUserIdentifierEnvKey           string
 	M2CacheDirectoryName             string
 	GradleCacheDirectoryName         string
 	PrivacyResultsPathSuffix         string
+	ResultsMetadataPathSuffix        string
 	PrivacyReportsDirectorySuffix    string
 	PrivadoRepository                string
 	PrivadoRepositoryName            string
@@ -58,6 +66,8 @@ type Configuration struct {
 }
 
 type ContainerConfiguration struct {
+	ImageRepository             string
+	ImageTag                    string
 	ImageURL                    string
 	DockerAccessKeyEnv          string
 	UserKeyVolumeDir            string
@@ -69,6 +79,7 @@ type ContainerConfiguration struct {
 	ExternalRulesVolumeDir      string
 	M2PackageCacheVolumeDir     string
 	GradlePackageCacheVolumeDir string
+	CertificatesVolumeDir       string
 	PrivadoCoreBinPath          string
 }
 
@@ -76,44 +87,33 @@ type ContainerConfiguration struct {
 func init() {
 	home, _ := homedir.Dir()
 
-	imageTag := "latest"
+	// the image tag is updated for the channel (see applyEffectiveUserConfiguration)
+	imageTag := GetChannelImageTag(ChannelStable)
 	telemetryHost := "cli.privado.ai"
 
-	// if PRIVADO_DEV is set, use developer env settings
-	isDev, _ := strconv.ParseBool(os.Getenv("PRIVADO_DEV"))
 	// if the running executable is running from the temp dir
 	// consider this to be run using "go run main.go",
-	// and use developer env settings
+	// and use the developer telemetry endpoint
 	if strings.HasPrefix(os.Args[0], os.TempDir()) {
-		isDev = true
-	}
-
-	if isDev {
 		telemetryHost = "t.cli.privado.ai"
-		// if PRIVADO_TAG is set, use the specified cli image tag
-		imageTag = os.Getenv("PRIVADO_TAG")
-		if imageTag == "" {
-			imageTag = "dev"
-		}
 	}
 
 	AppConfig = &Configuration{
 		HomeDirectory:                    home,
-		ConfigurationDirectory:           filepath.Join(home, ".privado"),
-		UserConfigurationFilePath:        filepath.Join(home, ".privado", "config.json"),
-		UserKeyDirectory:                 filepath.Join(home, ".privado", "keys"),
-		UserKeyPath:                      filepath.Join(home, ".privado", "keys", "user.key"),
 		CIUserIdentifierEnvKey:           "PRIVADO_CI_USER_ID",
 		M2CacheDirectoryName:             ".m2",
 		GradleCacheDirectoryName:         ".gradle",
 		PrivacyResultsPathSuffix:         filepath.Join(".privado", "privado.json"),
+		ResultsMetadataPathSuffix:        filepath.Join(".privado", "metadata.json"),
 		PrivadoRepository:                "https://github.com/Privado-Inc/privado-cli",
 		PrivadoRepositoryName:            "Privado-Inc/privado-cli",
 		PrivadoRepositoryReleaseFilename: fmt.Sprintf("privado-%s-%s.tar.gz", runtime.GOOS, runtime.GOARCH),
 		PrivadoTelemetryEndpoint:         fmt.Sprintf("https://%s/api/event?version=2", telemetryHost),
 		SlowdownTime:                     600 * time.Millisecond,
 		Container: &ContainerConfiguration{
-			ImageURL:                    fmt.Sprintf("public.ecr.aws/privado/privado:%s", imageTag),
+			ImageRepository:             defaultImageRepository,
+			ImageTag:                    imageTag,
+			ImageURL:                    fmt.Sprintf("%s:%s", defaultImageRepository, imageTag),
 			DockerAccessKeyEnv:          "PRIVADO_DOCKER_ACCESS_KEY",
 			UserKeyVolumeDir:            "/app/keys/user.key",
 			DockerKeyVolumeDir:          "/app/keys/docker.key",
@@ -124,12 +124,13 @@ func init() {
 			ExternalRulesVolumeDir:      "/app/external-rules",
 			M2PackageCacheVolumeDir:     "/root/.m2",
 			GradlePackageCacheVolumeDir: "/root/.gradle",
+			CertificatesVolumeDir:       "/app/certs",
 			PrivadoCoreBinPath:          "/usr/local/bin/core",
 		},
 	}
 
-	privadoCacheDir, _ := initPrivadoCacheDirectory()
-	AppConfig.CacheDirectory = privadoCacheDir
+	// configuration, cache and state directories (see resolvePrivadoDirectories)
+	setPrivadoDirectories(resolvePrivadoDirectories(home))
 }
 
 // returns existing privado cache directory
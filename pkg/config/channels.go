@@ -0,0 +1,71 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// release channels, for updates of Privado CLI and the privado image
+const (
+	// releases (GitHub releases/latest), and the latest image
+	ChannelStable = "stable"
+	// also prereleases (e.g. v1.2.0-beta.1), and the beta image
+	ChannelBeta = "beta"
+	// all releases, including nightly builds (e.g. v1.2.0-nightly.20221018), and the dev image
+	ChannelNightly = "nightly"
+)
+
+var Channels = []string{ChannelStable, ChannelBeta, ChannelNightly}
+
+// overrides the channel setting (e.g. for CI environments)
+const ChannelEnvKey = "PRIVADO_CHANNEL"
+
+var channelImageTags = map[string]string{
+	ChannelStable:  "latest",
+	ChannelBeta:    "beta",
+	ChannelNightly: "dev",
+}
+
+// Returns the release channel: PRIVADO_CHANNEL, or the channel setting
+// An invalid PRIVADO_CHANNEL is an error, rather than silently using stable
+func GetChannel() (string, error) {
+	if channel := os.Getenv(ChannelEnvKey); channel != "" {
+		channel = strings.ToLower(channel)
+		if _, exists := channelImageTags[channel]; !exists {
+			return ChannelStable, fmt.Errorf("invalid value '%s' for %s: expected one of %s", os.Getenv(ChannelEnvKey), ChannelEnvKey, strings.Join(Channels, ", "))
+		}
+		return channel, nil
+	}
+	return UserConfig.EffectiveConfig.Channel, nil
+}
+
+// returns the image tag for the channel (tag of the stable channel for unknown channels)
+func GetChannelImageTag(channel string) string {
+	if imageTag, exists := channelImageTags[channel]; exists {
+		return imageTag
+	}
+	return channelImageTags[ChannelStable]
+}
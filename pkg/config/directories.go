@@ -0,0 +1,121 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/Privado-Inc/privado-cli/pkg/fileutils"
+)
+
+// relocates all privado directories (configuration, keys, cache, state)
+const PrivadoHomeEnvKey = "PRIVADO_HOME"
+
+type privadoDirectories struct {
+	// empty cache directory: resolved by initPrivadoCacheDirectory
+	configuration, cache, state string
+}
+
+// Resolves the directories for configuration, cache and state:
+//  1. PRIVADO_HOME: all directories within it
+//  2. XDG base directories (XDG_CONFIG_HOME, XDG_CACHE_HOME, XDG_STATE_HOME), when set
+//  3. ~/.privado (and the system cache directory)
+//
+// An existing ~/.privado takes precedence over a new XDG configuration
+// directory, so that existing installations retain their user key
+func resolvePrivadoDirectories(home string) privadoDirectories {
+	if privadoHome := os.Getenv(PrivadoHomeEnvKey); privadoHome != "" {
+		privadoHome = fileutils.GetAbsolutePath(privadoHome)
+		return privadoDirectories{
+			configuration: privadoHome,
+			cache:         filepath.Join(privadoHome, "cache"),
+			state:         filepath.Join(privadoHome, "state"),
+		}
+	}
+
+	directories := privadoDirectories{configuration: filepath.Join(home, ".privado")}
+	if xdgConfigHome := os.Getenv("XDG_CONFIG_HOME"); xdgConfigHome != "" {
+		xdgConfigDirectory := filepath.Join(xdgConfigHome, "privado")
+		xdgExists, _ := fileutils.DoesFileExists(xdgConfigDirectory)
+		legacyExists, _ := fileutils.DoesFileExists(directories.configuration)
+		if xdgExists || !legacyExists {
+			directories.configuration = xdgConfigDirectory
+		}
+	}
+
+	if xdgCacheHome := os.Getenv("XDG_CACHE_HOME"); xdgCacheHome != "" {
+		directories.cache = filepath.Join(xdgCacheHome, "privado")
+	}
+
+	directories.state = filepath.Join(directories.configuration, "state")
+	if xdgStateHome := os.Getenv("XDG_STATE_HOME"); xdgStateHome != "" {
+		directories.state = filepath.Join(xdgStateHome, "privado")
+	}
+
+	return directories
+}
+
+// sets all paths in AppConfig derived from the directories
+func setPrivadoDirectories(directories privadoDirectories) {
+	AppConfig.ConfigurationDirectory = directories.configuration
+	AppConfig.UserConfigurationFilePath = filepath.Join(directories.configuration, "config.json")
+	AppConfig.UserKeyDirectory = filepath.Join(directories.configuration, "keys")
+	AppConfig.UserKeyPath = filepath.Join(directories.configuration, "keys", "user.key")
+	AppConfig.ErrorCatalogueFilePath = filepath.Join(directories.configuration, "errors.json")
+	AppConfig.StateDirectory = directories.state
+	AppConfig.TelemetryPreviewFilePath = filepath.Join(directories.state, "telemetry-preview.json")
+	AppConfig.TelemetryPreviewRequestFilePath = filepath.Join(directories.state, "telemetry-preview.requested")
+	AppConfig.UpdateBackupDirectory = filepath.Join(directories.state, "update-backup")
+
+	if directories.cache != "" {
+		if err := os.MkdirAll(directories.cache, os.ModePerm); err == nil {
+			AppConfig.CacheDirectory = directories.cache
+		}
+	} else {
+		AppConfig.CacheDirectory, _ = initPrivadoCacheDirectory()
+	}
+
+	// telemetry is not queued without a cache directory
+	AppConfig.TelemetryQueueDirectory = ""
+	if AppConfig.CacheDirectory != "" {
+		AppConfig.TelemetryQueueDirectory = filepath.Join(AppConfig.CacheDirectory, "telemetry")
+	}
+}
+
+// Relocates all directories to a new temporary directory, for environments
+// where the resolved directories are not writable (e.g. read-only home in CI)
+// Nothing is persisted across runs in this case
+func UseTemporaryPrivadoHome() (string, error) {
+	temporaryHome, err := os.MkdirTemp("", "privado-home-")
+	if err != nil {
+		return "", err
+	}
+
+	setPrivadoDirectories(privadoDirectories{
+		configuration: temporaryHome,
+		cache:         filepath.Join(temporaryHome, "cache"),
+		state:         filepath.Join(temporaryHome, "state"),
+	})
+	return temporaryHome, nil
+}
@@ -25,16 +25,33 @@ package config
 var ExtConfig *ExternalConfiguration
 
 type ExternalConfiguration struct {
-	GitHubAPIHost            string
-	GitHubReleasesEndpoint   string
-	GitHubReleaseDownloadURL string
+	GitHubAPIHost              string
+	GitHubReleasesEndpoint     string
+	GitHubReleaseByTagEndpoint string
+	GitHubReleasesListEndpoint string
+	GitHubReleaseDownloadURL   string
+
+	// SHA-256 checksums of all release assets (sha256sum format)
+	GitHubReleaseChecksumsFilename string
+
+	// detached (minisign) signatures of release assets: <asset><extension>
+	GitHubReleaseSignatureExtension string
+
+	// public keys trusted for release signatures (key rotation), signed by the embedded release keys
+	GitHubReleaseKeysFilename string
 }
 
 // init function for ExtConfig
 func init() {
 	ExtConfig = &ExternalConfiguration{
-		GitHubAPIHost:            "https://api.github.com",
-		GitHubReleasesEndpoint:   "/repos/${REPO_NAME}/releases/latest",
-		GitHubReleaseDownloadURL: "https://github.com/${REPO_NAME}/releases/${REPO_TAG}/download/${REPO_RELEASE_FILE}",
+		GitHubAPIHost:              "https://api.github.com",
+		GitHubReleasesEndpoint:     "/repos/${REPO_NAME}/releases/latest",
+		GitHubReleaseByTagEndpoint: "/repos/${REPO_NAME}/releases/tags/${REPO_TAG}",
+		GitHubReleasesListEndpoint: "/repos/${REPO_NAME}/releases?per_page=50",
+		GitHubReleaseDownloadURL:   "https://github.com/${REPO_NAME}/releases/download/${REPO_TAG}/${REPO_RELEASE_FILE}",
+
+		GitHubReleaseChecksumsFilename:  "checksums.txt",
+		GitHubReleaseSignatureExtension: ".minisig",
+		GitHubReleaseKeysFilename:       "privado-keys.pub",
 	}
 }
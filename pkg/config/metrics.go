@@ -0,0 +1,76 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// disables telemetry for all tools that respect it (https://consoledonottrack.com)
+const DoNotTrackEnvKey = "DO_NOT_TRACK"
+
+// overrides the metrics setting (e.g. for ephemeral CI containers with a fresh configuration)
+const MetricsEnabledEnvKey = "PRIVADO_METRICS_ENABLED"
+
+// Returns whether telemetry is enabled, and the source of the decision:
+//  1. DO_NOT_TRACK: disables telemetry when set to a true value
+//  2. PRIVADO_METRICS_ENABLED: when set to a boolean value
+//  3. the metrics setting in the configuration (or the selected profile)
+func GetMetricsEnabled() (enabled bool, source string) {
+	if doNotTrack, err := strconv.ParseBool(os.Getenv(DoNotTrackEnvKey)); err == nil && doNotTrack {
+		return false, fmt.Sprintf("%s=%s", DoNotTrackEnvKey, os.Getenv(DoNotTrackEnvKey))
+	}
+
+	if metricsEnabled, err := strconv.ParseBool(os.Getenv(MetricsEnabledEnvKey)); err == nil {
+		return metricsEnabled, fmt.Sprintf("%s=%s", MetricsEnabledEnvKey, os.Getenv(MetricsEnabledEnvKey))
+	}
+
+	source = fmt.Sprintf("metrics setting in %s", AppConfig.UserConfigurationFilePath)
+	if UserConfig.Profile != "" {
+		source = fmt.Sprintf("%s (profile: %s)", source, UserConfig.Profile)
+	}
+	return UserConfig.EffectiveConfig.MetricsEnabled, source
+}
+
+func IsMetricsEnabled() bool {
+	enabled, _ := GetMetricsEnabled()
+	return enabled
+}
+
+// Returns the collector endpoint for traces: the tracesEndpoint setting, or
+// the standard OpenTelemetry environment variables (for CI environments)
+func GetTracesEndpoint() string {
+	if UserConfig.EffectiveConfig.TracesEndpoint != "" {
+		return UserConfig.EffectiveConfig.TracesEndpoint
+	}
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); endpoint != "" {
+		return endpoint
+	}
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		return strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	}
+	return ""
+}
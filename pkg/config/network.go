@@ -0,0 +1,45 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package config
+
+import (
+	"os"
+
+	"github.com/Privado-Inc/privado-cli/pkg/fileutils"
+)
+
+// overrides the caBundlePath setting (e.g. for CI environments)
+const CABundlePathEnvKey = "PRIVADO_CA_BUNDLE"
+
+// Returns the absolute path of the additional CA bundle: PRIVADO_CA_BUNDLE,
+// or the caBundlePath setting. Empty when not configured
+func GetCABundlePath() string {
+	caBundlePath := os.Getenv(CABundlePathEnvKey)
+	if caBundlePath == "" {
+		caBundlePath = UserConfig.EffectiveConfig.CABundlePath
+	}
+	if caBundlePath == "" {
+		return ""
+	}
+	return fileutils.GetAbsolutePath(caBundlePath)
+}
@@ -0,0 +1,189 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Privado-Inc/privado-cli/pkg/auth"
+)
+
+// selects the profile, unless specified with --profile
+const PrivadoProfileEnvKey = "PRIVADO_PROFILE"
+
+const userConfigurationProfilesKey = "profiles"
+
+// profile names are used in file names (separate user keys)
+var profileNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func validateProfileName(name string) error {
+	if !profileNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid profile name '%s': use letters, digits, '-' and '_'", name)
+	}
+	return nil
+}
+
+func GetUserConfigurationProfileNames() []string {
+	names := []string{}
+	for name := range UserConfig.ConfigFile.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applies the settings of a profile on the configuration and returns errors for invalid
+// settings. Unknown settings are skipped, as they may be from a newer version
+func applyUserConfigurationProfile(configFile *UserConfigurationFromFile, profileSettings map[string]json.RawMessage) []string {
+	keys := []string{}
+	for key := range profileSettings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	errors := []string{}
+	for _, key := range keys {
+		setting, err := GetUserSetting(key)
+		if err != nil {
+			continue
+		}
+
+		value, err := decodeUserSettingValue(setting, profileSettings[key])
+		if err == nil {
+			err = setting.Set(configFile, value)
+		}
+		if err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+	return errors
+}
+
+// Selects the profile (none, if empty) and updates the effective configuration:
+// the settings in the file, overridden by the settings in the profile
+func SelectUserConfigurationProfile(name string) error {
+	effectiveConfig := *UserConfig.ConfigFile
+	if name != "" {
+		profileSettings, exists := UserConfig.ConfigFile.Profiles[name]
+		if !exists {
+			available := strings.Join(GetUserConfigurationProfileNames(), ", ")
+			if available == "" {
+				available = "none"
+			}
+			return fmt.Errorf("unknown profile '%s' (available: %s)", name, available)
+		}
+
+		if errors := applyUserConfigurationProfile(&effectiveConfig, profileSettings); len(errors) > 0 {
+			return fmt.Errorf("invalid settings in profile '%s': %s", name, strings.Join(errors, "; "))
+		}
+	}
+
+	UserConfig.Profile = name
+	UserConfig.EffectiveConfig = &effectiveConfig
+	return applyEffectiveUserConfiguration()
+}
+
+// updates the application configuration derived from the effective configuration
+func applyEffectiveUserConfiguration() error {
+	effectiveConfig := UserConfig.EffectiveConfig
+
+	// the image tag of the channel, unless a tag is set (invalid channels are
+	// reported by GetChannel, the stable tag is used for them)
+	channel, _ := GetChannel()
+	imageTag := GetChannelImageTag(channel)
+	if effectiveConfig.ImageTag != "" {
+		imageTag = effectiveConfig.ImageTag
+	}
+	AppConfig.Container.ImageRepository = effectiveConfig.ImageRepository
+	AppConfig.Container.ImageTag = imageTag
+	AppConfig.Container.ImageURL = fmt.Sprintf("%s:%s", effectiveConfig.ImageRepository, imageTag)
+
+	AppConfig.UserKeyDirectory = filepath.Join(AppConfig.ConfigurationDirectory, "keys")
+	AppConfig.UserKeyPath = filepath.Join(AppConfig.UserKeyDirectory, "user.key")
+	if effectiveConfig.SeparateUserKey && UserConfig.Profile != "" {
+		AppConfig.UserKeyDirectory = filepath.Join(AppConfig.ConfigurationDirectory, "keys", "profiles")
+		AppConfig.UserKeyPath = filepath.Join(AppConfig.UserKeyDirectory, fmt.Sprintf("%s.key", UserConfig.Profile))
+		if err := auth.BootstrapUserKey(AppConfig.UserKeyPath, AppConfig.UserKeyDirectory); err != nil {
+			return fmt.Errorf("cannot bootstrap user key for profile '%s': %w", UserConfig.Profile, err)
+		}
+	}
+	UserConfig.UserHash = auth.GetUserHash(AppConfig.UserKeyPath)
+
+	return nil
+}
+
+// Sets the setting in the profile, which is created if it does not exist
+func SetUserConfigurationProfileSetting(name string, setting *UserSetting, value string) error {
+	if err := validateProfileName(name); err != nil {
+		return err
+	}
+
+	// validate and normalize the value
+	validatedConfig := newDefaultUserConfigurationFromFile()
+	if err := setting.Set(validatedConfig, value); err != nil {
+		return err
+	}
+	rawValue, err := encodeUserSettingValue(setting, setting.Get(validatedConfig))
+	if err != nil {
+		return err
+	}
+
+	if UserConfig.ConfigFile.Profiles == nil {
+		UserConfig.ConfigFile.Profiles = map[string]map[string]json.RawMessage{}
+	}
+	if UserConfig.ConfigFile.Profiles[name] == nil {
+		UserConfig.ConfigFile.Profiles[name] = map[string]json.RawMessage{}
+	}
+	UserConfig.ConfigFile.Profiles[name][setting.Key] = rawValue
+
+	return nil
+}
+
+// Removes the setting from the profile, so that the value in the file applies
+func UnsetUserConfigurationProfileSetting(name string, setting *UserSetting) {
+	if profileSettings, exists := UserConfig.ConfigFile.Profiles[name]; exists {
+		delete(profileSettings, setting.Key)
+	}
+}
+
+// returns if the setting is overridden by the selected profile
+func IsSetInUserConfigurationProfile(setting *UserSetting) bool {
+	if UserConfig.Profile == "" {
+		return false
+	}
+	_, exists := UserConfig.ConfigFile.Profiles[UserConfig.Profile][setting.Key]
+	return exists
+}
+
+// encodes the value (from UserSetting.Get) as its JSON type
+func encodeUserSettingValue(setting *UserSetting, value string) (json.RawMessage, error) {
+	if setting.Type == UserSettingTypeBool {
+		return json.RawMessage(value), nil
+	}
+	return json.Marshal(value)
+}
@@ -24,31 +24,75 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/Privado-Inc/privado-cli/pkg/auth"
 	"github.com/Privado-Inc/privado-cli/pkg/fileutils"
 	"github.com/google/uuid"
 )
 
-var UserConfig = &UserConfiguration{
-	ConfigFile: &UserConfigurationFromFile{
-		MetricsEnabled: true,
-	},
-	SessionId: uuid.NewString(),
-}
+var UserConfig = newUserConfiguration()
 
 type UserConfiguration struct {
-	ConfigFile       *UserConfigurationFromFile
+	// settings as in the configuration file
+	ConfigFile *UserConfigurationFromFile
+
+	// settings in effect: the configuration file, overridden by the selected profile
+	// read settings from here, and write settings to ConfigFile (or the profile)
+	EffectiveConfig *UserConfigurationFromFile
+	Profile         string
+
 	UserHash         string
 	DockerAccessHash string
 	SessionId        string
 }
 
 type UserConfigurationFromFile struct {
-	MetricsEnabled     bool `json:"metrics"`
-	SyncToPrivadoCloud bool `json:"syncToPrivadoCloud"`
+	SchemaVersion      int    `json:"schemaVersion"`
+	MetricsEnabled     bool   `json:"metrics"`
+	SyncToPrivadoCloud bool   `json:"syncToPrivadoCloud"`
+	OpenBrowser        bool   `json:"openBrowser"`
+	ImageTag           string `json:"imageTag"`
+	PullPolicy         string `json:"pullPolicy"`
+
+	ImageRepository        string `json:"imageRepository"`
+	RulesDirectory         string `json:"rulesDirectory"`
+	SkipDependencyDownload bool   `json:"skipDependencyDownload"`
+	TracesFile             string `json:"tracesFile"`
+	TracesEndpoint         string `json:"tracesEndpoint"`
+	CABundlePath           string `json:"caBundlePath"`
+	Channel                string `json:"channel"`
+	SeparateUserKey        bool   `json:"separateUserKey,omitempty"`
+
+	// named sets of settings, overriding the settings above when selected
+	Profiles map[string]map[string]json.RawMessage `json:"profiles"`
+
+	// keys not known to this version (e.g. written by a newer version)
+	// are retained when the file is saved
+	unknownSettings map[string]json.RawMessage
+}
+
+func newUserConfiguration() *UserConfiguration {
+	configFile := newDefaultUserConfigurationFromFile()
+	return &UserConfiguration{
+		ConfigFile:      configFile,
+		EffectiveConfig: configFile,
+		SessionId:       uuid.NewString(),
+	}
+}
+
+// Lists all invalid settings in the configuration file
+type UserConfigurationError struct {
+	Path   string
+	Errors []string
+}
+
+func (e *UserConfigurationError) Error() string {
+	return fmt.Sprintf("invalid settings in %s:\n  - %s", e.Path, strings.Join(e.Errors, "\n  - "))
 }
 
 // Bootstraps user configuration file
@@ -63,8 +107,7 @@ func BootstrapUserConfiguration(resetConfig bool) error {
 
 	// if reset config, update session values that will be saved
 	if resetConfig {
-		UserConfig.ConfigFile.MetricsEnabled = true
-		UserConfig.ConfigFile.SyncToPrivadoCloud = false
+		*UserConfig.ConfigFile = *newDefaultUserConfigurationFromFile()
 	}
 
 	// if not, create directory and file
@@ -72,35 +115,93 @@ func BootstrapUserConfiguration(resetConfig bool) error {
 		return err
 	}
 
-	if err := SaveUserConfigurationFile(); err != nil {
+	// an invalid file is retained as a backup, so its settings can be restored
+	if resetConfig {
+		backupPath, err := backupInvalidUserConfigurationFile()
+		if err != nil {
+			return fmt.Errorf("cannot backup configuration file: %w", err)
+		}
+		if backupPath != "" {
+			fmt.Fprintln(os.Stderr, "> Invalid configuration file saved as:", backupPath)
+		}
+	}
+
+	if err := writeUserConfigurationFile(); err != nil {
 		return err
 	}
-	fmt.Println("> Generating configuration file:", AppConfig.UserConfigurationFilePath)
+	// status messages go to stderr so they do not interfere with the output of commands (e.g. json)
+	fmt.Fprintln(os.Stderr, "> Generating configuration file:", AppConfig.UserConfigurationFilePath)
 
 	return nil
 }
 
 // loads all required user configuration including from file into UserConfig
-func LoadUserConfiguration() {
+// An invalid configuration file is not fatal: the defaults are used for invalid
+// settings (or all settings, for invalid JSON) and warnings are returned
+func LoadUserConfiguration() (warnings []string) {
+	// upgrade the file from older versions before loading
+	if backupPath, err := MigrateUserConfigurationFile(); err != nil {
+		warnings = append(warnings, fmt.Sprintf("Cannot migrate configuration file (%s): %s", AppConfig.UserConfigurationFilePath, err))
+	} else if backupPath != "" {
+		fmt.Fprintf(os.Stderr, "> Migrated configuration file to version %d (backup: %s)\n", UserConfigurationSchemaVersion, backupPath)
+	}
+
 	// load config from file
 	if err := LoadUserConfigurationFile(UserConfig.ConfigFile); err != nil {
-		panic(fmt.Sprint(
-			fmt.Sprintf("Fatal: cannot load user configuration (%s): %s\n\n", AppConfig.UserConfigurationFilePath, err),
-			fmt.Sprintln("To reset privado configuration, simply delete the file and we will generate a new one for you!"),
+		warnings = append(warnings, fmt.Sprint(
+			fmt.Sprintf("Cannot load user configuration: %s\n", err),
+			getUserConfigurationRemediation(err),
 		))
 	}
 
-	// load other configs
-	// (move this to another function if these configs increases)
-	UserConfig.UserHash = auth.GetUserHash(AppConfig.UserKeyPath)
+	// select the profile from the environment, the effective configuration
+	// also loads other configs (user hash, image)
+	if err := SelectUserConfigurationProfile(os.Getenv(PrivadoProfileEnvKey)); err != nil {
+		warnings = append(warnings, fmt.Sprintf("Cannot select profile (%s): %s", PrivadoProfileEnvKey, err))
+		if err := SelectUserConfigurationProfile(""); err != nil {
+			warnings = append(warnings, err.Error())
+		}
+	}
+
+	return warnings
 }
 
 func LoadUserDockerHash(key string) {
 	UserConfig.DockerAccessHash = auth.CalculateSHA256Hash(key)
 }
 
+// invalid settings can be fixed with `privado config set`, but files that do
+// not parse are not overwritten (their settings would be lost)
+func getUserConfigurationRemediation(err error) string {
+	var configurationError *UserConfigurationError
+	if errors.As(err, &configurationError) {
+		return "Using default values for invalid settings. To fix, run `privado config set <key> <value>` or `privado config reset`"
+	}
+	return "Using default values for all settings. To fix, correct the file, or run `privado config reset` (the file is retained as a backup)"
+}
+
 // Saves the current UserConfig.ConfigFile to the configuration file
+// A file that does not parse is not overwritten, as its settings (including
+// profiles and unknown keys) would be lost: it must be fixed or reset
 func SaveUserConfigurationFile() error {
+	if isValid, err := isUserConfigurationFileValidJSON(); err != nil {
+		return err
+	} else if !isValid {
+		return fmt.Errorf("%s is not valid JSON, and is not overwritten. Correct the file, or run `privado config reset` (the file is retained as a backup)", AppConfig.UserConfigurationFilePath)
+	}
+
+	return writeUserConfigurationFile()
+}
+
+func writeUserConfigurationFile() error {
+	// files from a newer version retain their version
+	if UserConfig.ConfigFile.SchemaVersion < UserConfigurationSchemaVersion {
+		UserConfig.ConfigFile.SchemaVersion = UserConfigurationSchemaVersion
+	}
+	if UserConfig.ConfigFile.Profiles == nil {
+		UserConfig.ConfigFile.Profiles = map[string]map[string]json.RawMessage{}
+	}
+
 	configFileBytes, err := json.MarshalIndent(UserConfig.ConfigFile, "", "  ")
 	if err != nil {
 		return err
@@ -113,6 +214,35 @@ func SaveUserConfigurationFile() error {
 	return nil
 }
 
+// returns whether the configuration file is valid JSON (or does not exist)
+func isUserConfigurationFileValidJSON() (bool, error) {
+	data, err := os.ReadFile(AppConfig.UserConfigurationFilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	return json.Valid(data), nil
+}
+
+// copies a configuration file that is not valid JSON to a backup, and returns
+// the path of the backup (empty if the file is valid)
+func backupInvalidUserConfigurationFile() (string, error) {
+	if isValid, err := isUserConfigurationFileValidJSON(); err != nil || isValid {
+		return "", err
+	}
+
+	backupPath := fmt.Sprintf("%s.invalid.bak", AppConfig.UserConfigurationFilePath)
+	if err := fileutils.CopyFile(AppConfig.UserConfigurationFilePath, backupPath); err != nil {
+		return "", err
+	}
+	return backupPath, nil
+}
+
+// Loads the settings from the configuration file into userConfig.
+// Each setting is decoded and validated individually: invalid settings are
+// skipped (retaining the current value) and reported with their key in a
+// *UserConfigurationError. Unknown keys are retained for saving
 func LoadUserConfigurationFile(userConfig *UserConfigurationFromFile) error {
 	// read the config file
 	data, err := os.ReadFile(AppConfig.UserConfigurationFilePath)
@@ -120,10 +250,108 @@ func LoadUserConfigurationFile(userConfig *UserConfigurationFromFile) error {
 		return err
 	}
 
-	// load the config file into var
-	if err := json.Unmarshal(data, userConfig); err != nil {
-		return err
+	rawConfigFile := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &rawConfigFile); err != nil {
+		return describeUserConfigurationSyntaxError(data, err)
+	}
+
+	keys := []string{}
+	for key := range rawConfigFile {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	configurationError := &UserConfigurationError{Path: AppConfig.UserConfigurationFilePath}
+	userConfig.unknownSettings = map[string]json.RawMessage{}
+	for _, key := range keys {
+		rawValue := rawConfigFile[key]
+
+		if key == userConfigurationProfilesKey {
+			profiles := map[string]map[string]json.RawMessage{}
+			if err := json.Unmarshal(rawValue, &profiles); err != nil {
+				configurationError.Errors = append(configurationError.Errors, fmt.Sprintf("invalid value for %s: expected an object with profiles", key))
+				continue
+			}
+			for name, profileSettings := range profiles {
+				if err := validateProfileName(name); err != nil {
+					configurationError.Errors = append(configurationError.Errors, err.Error())
+				}
+				for _, profileError := range applyUserConfigurationProfile(newDefaultUserConfigurationFromFile(), profileSettings) {
+					configurationError.Errors = append(configurationError.Errors, fmt.Sprintf("profile '%s': %s", name, profileError))
+				}
+			}
+			userConfig.Profiles = profiles
+			continue
+		}
+
+		if key == userConfigurationSchemaVersionKey {
+			version, err := getUserConfigurationSchemaVersion(rawConfigFile)
+			if err != nil {
+				configurationError.Errors = append(configurationError.Errors, err.Error())
+				continue
+			}
+			userConfig.SchemaVersion = version
+			continue
+		}
+
+		setting, err := GetUserSetting(key)
+		if err != nil {
+			userConfig.unknownSettings[key] = rawValue
+			continue
+		}
+
+		if setting.ProfileOnly {
+			configurationError.Errors = append(configurationError.Errors, fmt.Sprintf("%s can only be set in a profile", key))
+			continue
+		}
+
+		value, err := decodeUserSettingValue(setting, rawValue)
+		if err == nil {
+			err = setting.Set(userConfig, value)
+		}
+		if err != nil {
+			configurationError.Errors = append(configurationError.Errors, err.Error())
+		}
 	}
 
+	if len(configurationError.Errors) > 0 {
+		return configurationError
+	}
 	return nil
 }
+
+// decodes the JSON value as a string for UserSetting.Set
+func decodeUserSettingValue(setting *UserSetting, rawValue json.RawMessage) (string, error) {
+	if setting.Type == UserSettingTypeBool {
+		value := false
+		if err := json.Unmarshal(rawValue, &value); err != nil {
+			return "", fmt.Errorf("invalid value %s for %s: expected true or false", string(rawValue), setting.Key)
+		}
+		return fmt.Sprint(value), nil
+	}
+
+	value := ""
+	if err := json.Unmarshal(rawValue, &value); err != nil {
+		return "", fmt.Errorf("invalid value %s for %s: expected a string", string(rawValue), setting.Key)
+	}
+	return value, nil
+}
+
+// includes unknown settings, so that they are retained in the file
+func (c *UserConfigurationFromFile) MarshalJSON() ([]byte, error) {
+	// the alias type does not inherit this method
+	type userConfigurationFromFile UserConfigurationFromFile
+	data, err := json.Marshal((*userConfigurationFromFile)(c))
+	if err != nil || len(c.unknownSettings) == 0 {
+		return data, err
+	}
+
+	configFile := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &configFile); err != nil {
+		return nil, err
+	}
+	for key, value := range c.unknownSettings {
+		configFile[key] = value
+	}
+	return json.Marshal(configFile)
+}
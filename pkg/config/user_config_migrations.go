@@ -0,0 +1,141 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/Privado-Inc/privado-cli/pkg/fileutils"
+)
+
+// Upgrades the configuration file from one schema version to the next
+type userConfigurationMigration func(configFile map[string]interface{})
+
+// userConfigurationMigrations[i] upgrades schema version i+1 to i+2
+// To change the schema (e.g. renamed keys, changed types): append a migration and
+// increment UserConfigurationSchemaVersion. New settings need no migration, as
+// missing settings use their default value
+var userConfigurationMigrations = []userConfigurationMigration{
+	// 1 -> 2: unversioned files (metrics, syncToPrivadoCloud) gain the settings managed by `privado config`
+	func(configFile map[string]interface{}) {
+		setMissingUserConfigurationValue(configFile, "openBrowser", true)
+		setMissingUserConfigurationValue(configFile, "imageTag", "")
+		setMissingUserConfigurationValue(configFile, "pullPolicy", PullPolicyAlways)
+	},
+}
+
+// must be len(userConfigurationMigrations) + 1
+const UserConfigurationSchemaVersion = 2
+
+const userConfigurationSchemaVersionKey = "schemaVersion"
+
+func setMissingUserConfigurationValue(configFile map[string]interface{}, key string, value interface{}) {
+	if _, exists := configFile[key]; !exists {
+		configFile[key] = value
+	}
+}
+
+// returns the schema version of the configuration file; unversioned files are version 1
+func getUserConfigurationSchemaVersion(configFile map[string]json.RawMessage) (int, error) {
+	rawVersion, exists := configFile[userConfigurationSchemaVersionKey]
+	if !exists {
+		return 1, nil
+	}
+
+	version := 0
+	if err := json.Unmarshal(rawVersion, &version); err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid value %s for %s: expected a positive number", string(rawVersion), userConfigurationSchemaVersionKey)
+	}
+	return version, nil
+}
+
+// Upgrades the configuration file to the current schema version, in place.
+// The original file is retained as a backup, and its path is returned when migrated.
+// Files from a newer version of Privado CLI, and invalid files (reported
+// by LoadUserConfigurationFile) are not modified
+func MigrateUserConfigurationFile() (backupPath string, err error) {
+	data, err := os.ReadFile(AppConfig.UserConfigurationFilePath)
+	if err != nil {
+		return "", err
+	}
+
+	rawConfigFile := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &rawConfigFile); err != nil {
+		return "", nil
+	}
+
+	version, err := getUserConfigurationSchemaVersion(rawConfigFile)
+	if err != nil || version >= UserConfigurationSchemaVersion {
+		return "", nil
+	}
+
+	configFile := map[string]interface{}{}
+	if err := json.Unmarshal(data, &configFile); err != nil {
+		return "", err
+	}
+	for _, migration := range userConfigurationMigrations[version-1:] {
+		migration(configFile)
+	}
+	configFile[userConfigurationSchemaVersionKey] = UserConfigurationSchemaVersion
+
+	migratedData, err := json.MarshalIndent(configFile, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	backupPath = fmt.Sprintf("%s.v%d.bak", AppConfig.UserConfigurationFilePath, version)
+	if err := fileutils.CopyFile(AppConfig.UserConfigurationFilePath, backupPath); err != nil {
+		return "", fmt.Errorf("cannot backup configuration file: %w", err)
+	}
+	if err := os.WriteFile(AppConfig.UserConfigurationFilePath, migratedData, 0644); err != nil {
+		return "", err
+	}
+
+	return backupPath, nil
+}
+
+// points syntax errors to the line and column in the file
+func describeUserConfigurationSyntaxError(data []byte, err error) error {
+	syntaxError, ok := err.(*json.SyntaxError)
+	if !ok {
+		return err
+	}
+
+	offset := int(syntaxError.Offset)
+	if offset > len(data) {
+		offset = len(data)
+	}
+
+	line, column := 1, 1
+	for _, b := range data[:offset] {
+		if b == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return fmt.Errorf("invalid JSON at line %d, column %d: %s", line, column, syntaxError)
+}
@@ -0,0 +1,235 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type UserSettingType string
+
+const (
+	UserSettingTypeBool   UserSettingType = "bool"
+	UserSettingTypeString UserSettingType = "string"
+	UserSettingTypeEnum   UserSettingType = "enum"
+)
+
+// image pull policies
+const (
+	PullPolicyAlways  = "always"
+	PullPolicyMissing = "missing"
+	PullPolicyNever   = "never"
+)
+
+// A setting in the configuration file, manageable with `privado config`
+// Values are read and written as strings, and validated for the type
+type UserSetting struct {
+	Key           string
+	Type          UserSettingType
+	Description   string
+	Default       string
+	AllowedValues []string
+
+	// can only be set in a profile (see SelectUserConfigurationProfile)
+	ProfileOnly bool
+
+	validate func(value string) error
+	get      func(configFile *UserConfigurationFromFile) string
+	set      func(configFile *UserConfigurationFromFile, value string)
+}
+
+// schema of the configuration file; add new settings here
+var UserSettings = []*UserSetting{
+	boolUserSetting("metrics", "Send telemetry events and performance metrics for Privado CLI", true,
+		func(c *UserConfigurationFromFile) *bool { return &c.MetricsEnabled }),
+	boolUserSetting("syncToPrivadoCloud", "Upload the scan results to Privado Dashboard after each scan", false,
+		func(c *UserConfigurationFromFile) *bool { return &c.SyncToPrivadoCloud }),
+	boolUserSetting("openBrowser", "Open the dashboard in a browser when a URL is available", true,
+		func(c *UserConfigurationFromFile) *bool { return &c.OpenBrowser }),
+	stringUserSetting("imageRepository", "Repository (registry) of the privado image used for scans", defaultImageRepository, nil, validateImageRepository,
+		func(c *UserConfigurationFromFile) *string { return &c.ImageRepository }),
+	stringUserSetting("imageTag", "Tag of the privado image used for scans (empty for the tag of the channel)", "", nil, validateImageTag,
+		func(c *UserConfigurationFromFile) *string { return &c.ImageTag }),
+	stringUserSetting("channel", "Release channel for updates of Privado CLI and the privado image: stable, beta (including prereleases) or nightly (including nightly builds)", ChannelStable,
+		Channels, nil,
+		func(c *UserConfigurationFromFile) *string { return &c.Channel }),
+	stringUserSetting("pullPolicy", "When to pull the privado image: always (latest image for each run), missing (only if not present), or never", PullPolicyAlways,
+		[]string{PullPolicyAlways, PullPolicyMissing, PullPolicyNever}, nil,
+		func(c *UserConfigurationFromFile) *string { return &c.PullPolicy }),
+	stringUserSetting("rulesDirectory", "Default config (with rules) directory for scans, used when `--config` is not specified", "", nil, nil,
+		func(c *UserConfigurationFromFile) *string { return &c.RulesDirectory }),
+	boolUserSetting("skipDependencyDownload", "Skip downloading dependencies in scans by default", false,
+		func(c *UserConfigurationFromFile) *bool { return &c.SkipDependencyDownload }),
+	stringUserSetting("tracesFile", "Append the durations of run phases as OTLP/JSON traces to this file", "", nil, nil,
+		func(c *UserConfigurationFromFile) *string { return &c.TracesFile }),
+	stringUserSetting("tracesEndpoint", "Export the durations of run phases as OTLP/JSON traces to this collector endpoint (e.g. http://localhost:4318/v1/traces)", "", nil, validateURL,
+		func(c *UserConfigurationFromFile) *string { return &c.TracesEndpoint }),
+	stringUserSetting("caBundlePath", "Path to a PEM bundle of additional CA certificates for outbound HTTPS requests (e.g. for a TLS-inspecting proxy)", "", nil, nil,
+		func(c *UserConfigurationFromFile) *string { return &c.CABundlePath }),
+	profileOnly(boolUserSetting("separateUserKey", "Use a separate user key for the profile", false,
+		func(c *UserConfigurationFromFile) *bool { return &c.SeparateUserKey })),
+}
+
+func profileOnly(setting *UserSetting) *UserSetting {
+	setting.ProfileOnly = true
+	return setting
+}
+
+func boolUserSetting(key, description string, defaultValue bool, field func(c *UserConfigurationFromFile) *bool) *UserSetting {
+	return &UserSetting{
+		Key:         key,
+		Type:        UserSettingTypeBool,
+		Description: description,
+		Default:     strconv.FormatBool(defaultValue),
+		get: func(c *UserConfigurationFromFile) string {
+			return strconv.FormatBool(*field(c))
+		},
+		set: func(c *UserConfigurationFromFile, value string) {
+			*field(c), _ = strconv.ParseBool(value)
+		},
+	}
+}
+
+// string settings with allowed values are enums
+func stringUserSetting(key, description, defaultValue string, allowedValues []string, validate func(string) error, field func(c *UserConfigurationFromFile) *string) *UserSetting {
+	settingType := UserSettingTypeString
+	if len(allowedValues) > 0 {
+		settingType = UserSettingTypeEnum
+	}
+
+	return &UserSetting{
+		Key:           key,
+		Type:          settingType,
+		Description:   description,
+		Default:       defaultValue,
+		AllowedValues: allowedValues,
+		validate:      validate,
+		get: func(c *UserConfigurationFromFile) string {
+			return *field(c)
+		},
+		set: func(c *UserConfigurationFromFile, value string) {
+			*field(c) = value
+		},
+	}
+}
+
+func GetUserSetting(key string) (*UserSetting, error) {
+	for _, setting := range UserSettings {
+		if setting.Key == key {
+			return setting, nil
+		}
+	}
+
+	keys := []string{}
+	for _, setting := range UserSettings {
+		keys = append(keys, setting.Key)
+	}
+	return nil, fmt.Errorf("unknown setting '%s' (available: %s)", key, strings.Join(keys, ", "))
+}
+
+func (s *UserSetting) Get(configFile *UserConfigurationFromFile) string {
+	return s.get(configFile)
+}
+
+// validates and sets the value, bool values are normalized (e.g. "1" is true)
+func (s *UserSetting) Set(configFile *UserConfigurationFromFile, value string) error {
+	switch s.Type {
+	case UserSettingTypeBool:
+		parsedValue, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value '%s' for %s: expected true or false", value, s.Key)
+		}
+		value = strconv.FormatBool(parsedValue)
+	case UserSettingTypeEnum:
+		isAllowed := false
+		for _, allowedValue := range s.AllowedValues {
+			if value == allowedValue {
+				isAllowed = true
+			}
+		}
+		if !isAllowed {
+			return fmt.Errorf("invalid value '%s' for %s: expected one of %s", value, s.Key, strings.Join(s.AllowedValues, ", "))
+		}
+	}
+
+	if s.validate != nil {
+		if err := s.validate(value); err != nil {
+			return fmt.Errorf("invalid value '%s' for %s: %s", value, s.Key, err)
+		}
+	}
+
+	s.set(configFile, value)
+	return nil
+}
+
+// resets the setting to its default value
+func (s *UserSetting) Unset(configFile *UserConfigurationFromFile) {
+	s.set(configFile, s.Default)
+}
+
+func (s *UserSetting) IsDefault(configFile *UserConfigurationFromFile) bool {
+	return s.get(configFile) == s.Default
+}
+
+// returns the configuration with default values for all settings
+func newDefaultUserConfigurationFromFile() *UserConfigurationFromFile {
+	configFile := &UserConfigurationFromFile{}
+	for _, setting := range UserSettings {
+		setting.Unset(configFile)
+	}
+	return configFile
+}
+
+// ref: https://github.com/distribution/distribution/blob/main/reference/regexp.go (simplified)
+var imageRepositoryRegex = regexp.MustCompile(`^[a-zA-Z0-9]+([._-][a-zA-Z0-9]+)*(:[0-9]+)?(/[a-z0-9]+([._-]+[a-z0-9]+)*)+$`)
+
+func validateImageRepository(value string) error {
+	if !imageRepositoryRegex.MatchString(value) {
+		return fmt.Errorf("not a valid image repository (e.g. %s)", defaultImageRepository)
+	}
+	return nil
+}
+
+func validateURL(value string) error {
+	if value == "" {
+		return nil
+	}
+	if parsedURL, err := url.Parse(value); err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		return fmt.Errorf("not a valid http(s) URL")
+	}
+	return nil
+}
+
+// ref: https://docs.docker.com/engine/reference/commandline/tag/
+var imageTagRegex = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
+func validateImageTag(value string) error {
+	if value != "" && !imageTagRegex.MatchString(value) {
+		return fmt.Errorf("not a valid image tag")
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package docker
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+	"unicode/utf16"
+
+	"github.com/Privado-Inc/privado-cli/pkg/config"
+)
+
+// Note: the CA bundle is made available in the container for all tools used
+// by the engine: as a PEM bundle (NODE_EXTRA_CA_CERTS), and as a JKS truststore
+// for the JVM (javax.net.ssl.trustStore). As the truststore replaces the
+// default truststore of the JVM, it also contains the CA certificates of the
+// host, when found, so that public repositories are still trusted (see
+// getHostCACertificates: the PEM bundle on linux and macOS, the system
+// certificate store on windows)
+
+const (
+	containerCABundleFilename   = "ca-bundle.pem"
+	containerTrustStoreFilename = "truststore.jks"
+
+	// the default password of JVM truststores (cacerts), it only protects integrity
+	containerTrustStorePassword = "changeit"
+)
+
+func readPEMCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	certificates := []*x509.Certificate{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if certificate, err := x509.ParseCertificate(block.Bytes); err == nil {
+			certificates = append(certificates, certificate)
+		}
+	}
+
+	if len(certificates) == 0 {
+		return nil, fmt.Errorf("no PEM certificates found in %s", path)
+	}
+	return certificates, nil
+}
+
+// Prepares the certificates directory mounted in the container (see
+// OptionWithCABundle) from the CA bundle, and returns its path
+func PrepareContainerCertificates(caBundlePath string) (string, error) {
+	certificates, err := readPEMCertificates(caBundlePath)
+	if err != nil {
+		return "", fmt.Errorf("cannot read CA bundle: %w", err)
+	}
+
+	if config.AppConfig.CacheDirectory == "" {
+		return "", fmt.Errorf("cache directory is not available")
+	}
+	certificatesDirectory := filepath.Join(config.AppConfig.CacheDirectory, "certs")
+	if err := os.MkdirAll(certificatesDirectory, os.ModePerm); err != nil {
+		return "", err
+	}
+
+	bundle := &bytes.Buffer{}
+	for _, certificate := range certificates {
+		pem.Encode(bundle, &pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})
+	}
+	if err := os.WriteFile(filepath.Join(certificatesDirectory, containerCABundleFilename), bundle.Bytes(), 0644); err != nil {
+		return "", err
+	}
+
+	trustStore, err := encodeJavaTrustStore(append(certificates, getHostCACertificates()...), containerTrustStorePassword)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(certificatesDirectory, containerTrustStoreFilename), trustStore, 0644); err != nil {
+		return "", err
+	}
+
+	return certificatesDirectory, nil
+}
+
+// Encodes the certificates as trusted certificate entries of a JKS keystore
+// ref: sun.security.provider.JavaKeyStore (engineStore)
+func encodeJavaTrustStore(certificates []*x509.Certificate, password string) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	writeUTF := func(value string) {
+		binary.Write(buffer, binary.BigEndian, uint16(len(value)))
+		buffer.WriteString(value)
+	}
+
+	binary.Write(buffer, binary.BigEndian, uint32(0xFEEDFEED)) // magic
+	binary.Write(buffer, binary.BigEndian, uint32(2))          // version
+	binary.Write(buffer, binary.BigEndian, uint32(len(certificates)))
+
+	timestamp := time.Now().UnixNano() / int64(time.Millisecond)
+	for i, certificate := range certificates {
+		binary.Write(buffer, binary.BigEndian, uint32(2)) // trusted certificate entry
+		writeUTF(fmt.Sprintf("privado-ca-%d", i))
+		binary.Write(buffer, binary.BigEndian, timestamp)
+		writeUTF("X.509")
+		binary.Write(buffer, binary.BigEndian, uint32(len(certificate.Raw)))
+		buffer.Write(certificate.Raw)
+	}
+
+	// integrity digest: SHA-1 of the password (UTF-16BE), a fixed phrase and the keystore
+	digest := sha1.New()
+	for _, char := range utf16.Encode([]rune(password)) {
+		binary.Write(digest, binary.BigEndian, char)
+	}
+	digest.Write([]byte("Mighty Aphrodite"))
+	digest.Write(buffer.Bytes())
+	buffer.Write(digest.Sum(nil))
+
+	return buffer.Bytes(), nil
+}
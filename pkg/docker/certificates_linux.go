@@ -0,0 +1,51 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package docker
+
+import (
+	"crypto/x509"
+	"os"
+)
+
+// CA certificates of the host (SSL_CERT_FILE, or the common locations on linux)
+var hostCABundlePaths = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+	"/etc/ssl/ca-bundle.pem",
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+	"/etc/ssl/cert.pem",
+}
+
+func getHostCACertificates() []*x509.Certificate {
+	paths := hostCABundlePaths
+	if sslCertFile := os.Getenv("SSL_CERT_FILE"); sslCertFile != "" {
+		paths = append([]string{sslCertFile}, paths...)
+	}
+
+	for _, path := range paths {
+		if certificates, err := readPEMCertificates(path); err == nil {
+			return certificates
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package docker
+
+import (
+	"crypto/x509"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// CA certificates of the host: SSL_CERT_FILE, or the trusted root
+// certificates of the system certificate store (there is no PEM bundle)
+func getHostCACertificates() []*x509.Certificate {
+	if sslCertFile := os.Getenv("SSL_CERT_FILE"); sslCertFile != "" {
+		if certificates, err := readPEMCertificates(sslCertFile); err == nil {
+			return certificates
+		}
+	}
+
+	storeName, err := syscall.UTF16PtrFromString("ROOT")
+	if err != nil {
+		return nil
+	}
+	store, err := syscall.CertOpenSystemStore(0, storeName)
+	if err != nil {
+		return nil
+	}
+	defer syscall.CertCloseStore(store, 0)
+
+	certificates := []*x509.Certificate{}
+	var context *syscall.CertContext
+	for {
+		// the previous context is freed by the next call
+		context, err = syscall.CertEnumCertificatesInStore(store, context)
+		if err != nil || context == nil {
+			break
+		}
+
+		encodedCertificate := (*[1 << 20]byte)(unsafe.Pointer(context.EncodedCert))[:context.Length:context.Length]
+		if certificate, err := x509.ParseCertificate(encodedCertificate); err == nil {
+			certificates = append(certificates, certificate)
+		}
+	}
+	return certificates
+}
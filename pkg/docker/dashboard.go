@@ -0,0 +1,91 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Privado-Inc/privado-cli/pkg/config"
+	"github.com/Privado-Inc/privado-cli/pkg/output"
+	"github.com/Privado-Inc/privado-cli/pkg/runlog"
+	"github.com/Privado-Inc/privado-cli/pkg/telemetry"
+)
+
+// saves the dashboard URL in the results metadata of the scanned
+// repository and in the dashboard URL file (if specified), so it
+// remains available when a browser cannot be opened
+func saveDashboardURL(url string, runOptions runImageHandler) {
+	if runOptions.volumes.sourceCodeVolumeEnabled {
+		metadataPath := filepath.Join(runOptions.volumes.sourceCodeVolumeHost, config.AppConfig.ResultsMetadataPathSuffix)
+		if err := updateResultsMetadata(metadataPath, map[string]interface{}{
+			"dashboardUrl": url,
+			"updatedAt":    time.Now().UTC().Format(time.RFC3339),
+		}); err != nil {
+			warnDashboardURL(fmt.Sprintf("Could not save dashboard URL in results metadata (%s): %v", metadataPath, err))
+		} else {
+			runlog.DefaultInstance.Printf("Saved dashboard URL in results metadata: %s", metadataPath)
+		}
+	}
+
+	if runOptions.dashboardURLFilePath != "" {
+		if err := os.WriteFile(runOptions.dashboardURLFilePath, []byte(url+"\n"), 0644); err != nil {
+			warnDashboardURL(fmt.Sprintf("Could not write dashboard URL file (%s): %v", runOptions.dashboardURLFilePath, err))
+		} else {
+			runlog.DefaultInstance.Printf("Saved dashboard URL in file: %s", runOptions.dashboardURLFilePath)
+		}
+	}
+}
+
+// updates the values in the results metadata file, retaining any other values
+func updateResultsMetadata(metadataPath string, values map[string]interface{}) error {
+	metadata := map[string]interface{}{}
+	if data, err := os.ReadFile(metadataPath); err == nil {
+		// a corrupt metadata file is replaced
+		_ = json.Unmarshal(data, &metadata)
+	}
+
+	for key, value := range values {
+		metadata[key] = value
+	}
+
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(metadataPath), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(metadataPath, data, 0644)
+}
+
+func warnDashboardURL(warningMsg string) {
+	output.Println("[WARN]: ", warningMsg)
+	output.Emit(output.Event{Event: output.EventWarning, Message: warningMsg})
+	runlog.DefaultInstance.Printf("%s", warningMsg)
+	telemetry.DefaultInstance.RecordArrayMetric("warning", warningMsg)
+}
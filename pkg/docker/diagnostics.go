@@ -0,0 +1,133 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package docker
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// maximum time for each diagnostic request to the daemon
+const diagnosticsTimeout = 10 * time.Second
+
+// Details of the daemon (Docker, or a compatible engine like Podman)
+type DaemonDiagnostics struct {
+	Host            string
+	Engine          string
+	ServerVersion   string
+	APIVersion      string
+	OperatingSystem string
+	MemTotal        int64
+	DockerRootDir   string
+}
+
+type ImageDiagnostics struct {
+	Image   string
+	Digest  string
+	Created string
+	Size    int64
+}
+
+// returns the daemon host the client connects to (e.g. unix:///var/run/docker.sock)
+func GetDaemonHost() string {
+	client, err := getDefaultDockerClient()
+	if err != nil {
+		return ""
+	}
+	defer client.Close()
+
+	return client.DaemonHost()
+}
+
+// connects to the daemon and returns its details
+func GetDaemonDiagnostics() (*DaemonDiagnostics, error) {
+	client, err := getDefaultDockerClient()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticsTimeout)
+	defer cancel()
+
+	version, err := client.ServerVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info, err := client.Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	diagnostics := &DaemonDiagnostics{
+		Host:            client.DaemonHost(),
+		Engine:          version.Platform.Name,
+		ServerVersion:   version.Version,
+		APIVersion:      client.ClientVersion(),
+		OperatingSystem: info.OperatingSystem,
+		MemTotal:        info.MemTotal,
+		DockerRootDir:   info.DockerRootDir,
+	}
+
+	// podman identifies itself as a component
+	for _, component := range version.Components {
+		if strings.Contains(strings.ToLower(component.Name), "podman") {
+			diagnostics.Engine = component.Name
+		}
+	}
+	if diagnostics.Engine == "" {
+		diagnostics.Engine = "Docker Engine"
+	}
+
+	return diagnostics, nil
+}
+
+// returns the details of the local image, or nil if the image is not present
+func GetImageDiagnostics(image string) (*ImageDiagnostics, error) {
+	dockerClient, err := getDefaultDockerClient()
+	if err != nil {
+		return nil, err
+	}
+	defer dockerClient.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticsTimeout)
+	defer cancel()
+
+	imageInfo, _, err := dockerClient.ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &ImageDiagnostics{
+		Image:   image,
+		Digest:  getImageDigest(dockerClient, image),
+		Created: imageInfo.Created,
+		Size:    imageInfo.Size,
+	}, nil
+}
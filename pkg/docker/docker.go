@@ -23,14 +23,18 @@
 package docker
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"io"
 	"os"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Privado-Inc/privado-cli/pkg/config"
+	"github.com/Privado-Inc/privado-cli/pkg/output"
+	"github.com/Privado-Inc/privado-cli/pkg/runlog"
 	"github.com/Privado-Inc/privado-cli/pkg/telemetry"
 	"github.com/Privado-Inc/privado-cli/pkg/utils"
 	"github.com/docker/docker/api/types"
@@ -38,12 +42,33 @@ import (
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/moby/term"
 )
 
-type containerOutputProcessor struct {
-	messages []string
-	matchFn  func(string)
+// maximum time to wait for the container output to be processed after the container exits
+const containerOutputDrainTimeout = 10 * time.Second
+
+// time given to the engine to stop after an interrupt, before it is killed
+const containerStopGracePeriod = 30 * time.Second
+
+// Returned by RunImage when the run is interrupted by a signal
+type InterruptedError struct {
+	Signal os.Signal
+}
+
+func (e *InterruptedError) Error() string {
+	return fmt.Sprintf("interrupted by signal: %s", e.Signal)
+}
+
+// Returned by RunImage when the container exits with a non-zero status
+// code that is not classified as an EngineError
+type ContainerExitError struct {
+	StatusCode int64
+}
+
+func (e *ContainerExitError) Error() string {
+	return fmt.Sprintf("container exited with status code: %d", e.StatusCode)
 }
 
 func getDefaultDockerClient() (*client.Client, error) {
@@ -55,14 +80,15 @@ func getDefaultDockerClient() (*client.Client, error) {
 	return client, nil
 }
 
-func getBaseContainerConfig(image string) *container.Config {
+// a TTY and stdin are only allocated for interactive sessions
+func getBaseContainerConfig(image string, interactive bool) *container.Config {
 	config := &container.Config{
 		Image:        image,
-		AttachStdin:  true,
+		AttachStdin:  interactive,
 		AttachStdout: true,
 		AttachStderr: true,
-		OpenStdin:    true,
-		Tty:          true,
+		OpenStdin:    interactive,
+		Tty:          interactive,
 	}
 	return config
 }
@@ -142,6 +168,17 @@ func getContainerHostConfig(volumes containerVolumes) *container.HostConfig {
 			},
 		)
 	}
+	if volumes.certificatesVolumeEnabled {
+		hostConfig.Mounts = append(
+			hostConfig.Mounts,
+			mount.Mount{
+				Type:     "bind",
+				Source:   volumes.certificatesVolumeHost,
+				Target:   config.AppConfig.Container.CertificatesVolumeDir,
+				ReadOnly: true,
+			},
+		)
+	}
 
 	return hostConfig
 }
@@ -167,7 +204,7 @@ func GetEnvsFromDockerImage(imageURL string) ([]EnvVar, error) {
 	return sanitizedEnvs, nil
 }
 
-func GetPrivadoDockerAccessKey(pullImage bool) (string, error) {
+func GetPrivadoDockerAccessKey(pullImage bool) (key string, err error) {
 	imageURL := config.AppConfig.Container.ImageURL
 
 	if pullImage {
@@ -175,11 +212,14 @@ func GetPrivadoDockerAccessKey(pullImage bool) (string, error) {
 		if err != nil {
 			return "", err
 		}
-		if err := PullLatestImage(imageURL, client); err != nil {
+		if err := pullImageWithPolicy(imageURL, client); err != nil {
 			return "", err
 		}
 	}
 
+	accessKeySpan := telemetry.StartSpan("image.accessKey")
+	defer func() { accessKeySpan.Finish(err) }()
+
 	envs, err := GetEnvsFromDockerImage(imageURL)
 	if err != nil {
 		return "", err
@@ -204,89 +244,163 @@ func PullLatestImage(image string, client *client.Client) (err error) {
 
 	ctx := context.Background()
 
-	fmt.Println("\n> Pulling the latest image:", image)
+	output.Println("\n> Pulling the latest image:", image)
+	output.Emit(output.Event{Event: output.EventPhaseStart, Phase: "image.pull", Image: image})
+	runlog.DefaultInstance.Printf("Pulling image: %s", image)
 	reader, err := client.ImagePull(ctx, image, types.ImagePullOptions{})
 	if err != nil {
 		return err
 	}
 
+	// the pull stream is always consumed, as the pull completes with the stream
 	id, isTerm := term.GetFdInfo(os.Stdout)
-	_ = jsonmessage.DisplayJSONMessagesStream(reader, os.Stdout, id, isTerm, nil)
+	textWriter := output.TextWriter()
+	if textWriter != os.Stdout || !output.IsInteractive() {
+		isTerm = false
+	}
+	_ = jsonmessage.DisplayJSONMessagesStream(reader, textWriter, id, isTerm, nil)
 
 	defer reader.Close()
-	io.Copy(os.Stdout, reader)
+	io.Copy(textWriter, reader)
+
+	digest := getImageDigest(client, image)
+	runlog.DefaultInstance.Printf("Pulled image: %s (%s)", image, digest)
+	output.Emit(output.Event{Event: output.EventPhaseEnd, Phase: "image.pull", Image: image})
+	output.Emit(output.Event{Event: output.EventImage, Image: image, Digest: digest})
 
 	return nil
 }
 
-func attachContainerOutput(client *client.Client, ctx context.Context, containerId string) (*bufio.Reader, error) {
+// pulls the image as per the pull policy in the user configuration
+func pullImageWithPolicy(image string, client *client.Client) (err error) {
+	pullPolicy := config.UserConfig.EffectiveConfig.PullPolicy
+
+	pullSpan := telemetry.StartSpan("image.pull")
+	pullSpan.SetAttribute("image", image)
+	pullSpan.SetAttribute("pullPolicy", pullPolicy)
+	defer func() { pullSpan.Finish(err) }()
+
+	if pullPolicy == config.PullPolicyNever || pullPolicy == config.PullPolicyMissing {
+		if _, _, err := client.ImageInspectWithRaw(context.Background(), image); err == nil {
+			runlog.DefaultInstance.Printf("Using local image (pull policy: %s): %s", pullPolicy, image)
+			pullSpan.SetAttribute("pulled", false)
+			output.Emit(output.Event{Event: output.EventImage, Image: image, Digest: getImageDigest(client, image)})
+			return nil
+		} else if pullPolicy == config.PullPolicyNever {
+			return fmt.Errorf("image %s is not available locally and the pull policy is '%s': %w", image, pullPolicy, err)
+		}
+	}
+
+	pullSpan.SetAttribute("pulled", true)
+	return PullLatestImage(image, client)
+}
+
+// returns the repository digest of the image when available, or its id otherwise
+func getImageDigest(client *client.Client, image string) string {
+	imageInfo, _, err := client.ImageInspectWithRaw(context.Background(), image)
+	if err != nil {
+		return ""
+	}
+
+	if len(imageInfo.RepoDigests) > 0 {
+		if parts := strings.SplitN(imageInfo.RepoDigests[0], "@", 2); len(parts) == 2 {
+			return parts[1]
+		}
+	}
+
+	return imageInfo.ID
+}
+
+// attaches to the container streams and returns the container output;
+// the caller must close both the returned response and the reader
+func attachContainerOutput(client *client.Client, ctx context.Context, containerId string, interactive bool) (*types.HijackedResponse, io.ReadCloser, error) {
 	waiter, err := client.ContainerAttach(ctx, containerId, types.ContainerAttachOptions{
 		Stderr: true,
 		Stdout: true,
-		Stdin:  true,
+		Stdin:  interactive,
 		Stream: true,
 		Logs:   true,
 	})
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	if interactive {
+		// forward stdin to the container (with a TTY)
+		go io.Copy(waiter.Conn, os.Stdin)
+		return &waiter, io.NopCloser(waiter.Reader), nil
 	}
-	// attach stdin by default for now
-	go io.Copy(waiter.Conn, os.Stdin)
 
-	return waiter.Reader, err
+	// without a TTY, stdout and stderr are multiplexed in a single stream
+	outputReader, outputWriter := io.Pipe()
+	go func() {
+		_, err := stdcopy.StdCopy(outputWriter, outputWriter, waiter.Reader)
+		outputWriter.CloseWithError(err)
+	}()
+
+	return &waiter, outputReader, nil
 }
 
-func processAttachedContainerOutput(reader *bufio.Reader, attachStdOut bool, outputProcessors []containerOutputProcessor) {
-	// noticed we are missing output due to
-	// this kind of usage
-	// rather print
-	// if attachStdOut {
-	// 	go io.Copy(os.Stdout, reader)
-	// 	go io.Copy(os.Stderr, reader)
-	// }
+// returns the sinks for the attached container output:
+// stdout (when attached), the run log and the output processors
+func getContainerOutputSinks(attachStdOut bool, outputProcessors []containerOutputProcessor, phaseTracker *enginePhaseTracker) []containerOutputSink {
+	sinks := []containerOutputSink{}
 
-	if len(outputProcessors) <= 0 {
-		return
+	if attachStdOut {
+		sinks = append(sinks, func(line containerOutputLine) {
+			if output.IsJSON() {
+				if processedLine := strings.TrimSpace(line.text); processedLine != "" {
+					output.Emit(output.Event{Event: output.EventEngineLog, Message: processedLine})
+				}
+				return
+			}
+
+			// the raw line may contain escape sequences and carriage returns, which
+			// only render correctly in a terminal: print plain lines otherwise
+			rawLine := line.raw
+			if !output.IsInteractive() {
+				rawLine = line.text + "\n"
+			}
+			if phaseTracker != nil {
+				phaseTracker.print(rawLine)
+			} else {
+				output.Print(rawLine)
+			}
+		})
 	}
 
-	go func() {
-		for {
-			outputLine, _ := reader.ReadString('\n')
-			if attachStdOut {
-				fmt.Print(outputLine)
+	if runlog.DefaultInstance != nil {
+		sinks = append(sinks, func(line containerOutputLine) {
+			if line.text != "" {
+				runlog.DefaultInstance.LogContainerOutput(line.text)
 			}
+		})
+	}
 
-			// process each line in parallel goroutines so output
-			// does not get blocked and we do not skip anything in
-			// either approach of getting stdout (copy, print)
-			// also, this is efficient
-			go func(outputLine string) {
-				for _, outputProcessor := range outputProcessors {
-					for _, message := range outputProcessor.messages {
-						if strings.Contains(outputLine, message) {
-							processedLine := strings.TrimSpace(strings.TrimSuffix(outputLine, "\n"))
-							outputProcessor.matchFn(processedLine)
-						}
-					}
+	for _, outputProcessor := range outputProcessors {
+		sinks = append(sinks, newContainerOutputProcessorSink(outputProcessor))
+	}
 
-				}
-			}(outputLine)
-		}
-	}()
+	return sinks
 }
 
-func WaitForContainer(client *client.Client, ctx context.Context, containerId string) error {
+// waits for the container to stop and returns its exit status code
+func WaitForContainer(client *client.Client, ctx context.Context, containerId string) (int64, error) {
 	statusCh, errCh := client.ContainerWait(ctx, containerId, container.WaitConditionNotRunning)
 	select {
 	case err := <-errCh:
 		if err != nil {
-			return err
+			return -1, err
 		}
-	case <-statusCh:
+	case status := <-statusCh:
+		if status.Error != nil {
+			return status.StatusCode, fmt.Errorf("%s", status.Error.Message)
+		}
+		return status.StatusCode, nil
 	}
 
-	return nil
+	return -1, nil
 }
 
 func RemoveContainerForcefully(client *client.Client, ctx context.Context, containerId string) error {
@@ -300,13 +414,18 @@ func RemoveContainerForcefully(client *client.Client, ctx context.Context, conta
 	)
 }
 
-func StopContainer(client *client.Client, ctx context.Context, containerId string) error {
-	return client.ContainerStop(ctx, containerId, nil)
+// stops the container with a stop signal, and kills it
+// if it is still running after the grace period
+func StopContainer(client *client.Client, ctx context.Context, containerId string, gracePeriod time.Duration) error {
+	return client.ContainerStop(ctx, containerId, &gracePeriod)
 }
 
-func RunImage(opts ...RunImageOption) error {
+func RunImage(opts ...RunImageOption) (err error) {
 	runOptions := newRunImageHandler(opts)
-	ctx := context.Background()
+
+	// cancelled on return, which terminates the container output pipeline
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	client, err := getDefaultDockerClient()
 	if err != nil {
@@ -316,29 +435,33 @@ func RunImage(opts ...RunImageOption) error {
 	image := config.AppConfig.Container.ImageURL
 	// Pull image
 	if runOptions.pullLatestImage {
-		if err := PullLatestImage(image, client); err != nil {
+		if err := pullImageWithPolicy(image, client); err != nil {
 			return err
 		}
 	}
 
 	// Generate container configurations
-	containerConfig := getBaseContainerConfig(image)
+	containerConfig := getBaseContainerConfig(image, output.IsInteractive())
 	containerConfig.Entrypoint = runOptions.entrypoint
 	containerConfig.Cmd = runOptions.args
-	containerConfig.Env = runOptions.environmentVars
+	containerConfig.Env = getContainerEnvironment(runOptions)
 	hostConfig := getContainerHostConfig(runOptions.volumes)
 
 	telemetry.DefaultInstance.RecordAtomicMetric("dockerCmd", strings.Join(containerConfig.Cmd, " "))
+	logContainerConfiguration(containerConfig, hostConfig)
 
 	// Create container
+	createSpan := telemetry.StartSpan("container.create")
 	creationResponse, err := client.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, "")
+	createSpan.Finish(err)
 	if err != nil {
 		return err
 	}
 	if len(creationResponse.Warnings) > 0 {
-		fmt.Println("\n> Encountered warnings:")
+		output.Println("\n> Encountered warnings:")
 		for i, warn := range creationResponse.Warnings {
-			fmt.Println(i+1, warn)
+			output.Println(i+1, warn)
+			output.Emit(output.Event{Event: output.EventWarning, Message: warn})
 			telemetry.DefaultInstance.RecordArrayMetric("warning", warn)
 		}
 	}
@@ -356,6 +479,13 @@ func RunImage(opts ...RunImageOption) error {
 				url := utils.ExtractURLFromString(message)
 				if url != "" {
 					telemetry.DefaultInstance.RecordAtomicMetric("didParseCloudLink", true)
+					output.Emit(output.Event{Event: output.EventDashboardURL, URL: url})
+					saveDashboardURL(url, runOptions)
+
+					if runOptions.disableBrowser {
+						runlog.DefaultInstance.Printf("Browser disabled, not opening: %s", url)
+						return
+					}
 					err := utils.OpenURLInBrowser(url)
 					if err != nil {
 						telemetry.DefaultInstance.RecordArrayMetric("error", err)
@@ -370,56 +500,173 @@ func RunImage(opts ...RunImageOption) error {
 		containerOutputProcessors = append(containerOutputProcessors, containerOutputProcessor{
 			messages: runOptions.exitOnErrorTriggerMessages,
 			matchFn: func(message string) {
-				fmt.Println("\n> Some error occurred")
+				output.Println("\n> Some error occurred")
 				if message != "" {
-					// reset any color from internal process
-					fmt.Println("Find more details below:\n", message, "\033[0m")
+					if output.IsInteractive() {
+						// reset any color from internal process
+						output.Println("Find more details below:\n", message, "\033[0m")
+					} else {
+						output.Println("Find more details below:\n", message)
+					}
+					output.Emit(output.Event{Event: output.EventWarning, Message: message})
 					telemetry.DefaultInstance.RecordArrayMetric("warning", message)
 				}
-				fmt.Println("\n> If this is an unexpected output, please try again or open an issue here: ", config.AppConfig.PrivadoRepository)
-				fmt.Println("> Terminating..")
+				output.Println("\n> If this is an unexpected output, please try again or open an issue here: ", config.AppConfig.PrivadoRepository)
+				output.Println("> Terminating..")
+				runlog.DefaultInstance.Printf("Terminating on error message: %s", message)
 				RemoveContainerForcefully(client, ctx, creationResponse.ID)
 			},
 		})
 	}
 
-	if runOptions.attachOutput || len(containerOutputProcessors) > 0 {
-		reader, err := attachContainerOutput(client, ctx, creationResponse.ID)
+	var phaseTracker *enginePhaseTracker
+	engineSpan := telemetry.StartSpan("engine.run")
+	engineSpan.SetAttribute("image", image)
+	defer func() { engineSpan.Finish(err) }()
+
+	if runOptions.showProgress {
+		phaseTracker = newEnginePhaseTracker(engineSpan)
+		containerOutputProcessors = append(containerOutputProcessors, phaseTracker.getOutputProcessor())
+	}
+
+	var errorClassifier *engineErrorClassifier
+	sinks := getContainerOutputSinks(runOptions.attachOutput, containerOutputProcessors, phaseTracker)
+	if len(runOptions.errorCatalogue) > 0 {
+		errorClassifier = newEngineErrorClassifier(runOptions.errorCatalogue)
+		sinks = append(sinks, errorClassifier.getOutputSink())
+	}
+
+	// closed when all container output is processed
+	var containerOutputDone <-chan struct{}
+	if len(sinks) > 0 {
+		attachResponse, outputReader, err := attachContainerOutput(client, ctx, creationResponse.ID, containerConfig.Tty)
 		if err != nil {
 			return err
 		}
+		defer attachResponse.Close()
+		defer outputReader.Close()
 
-		processAttachedContainerOutput(reader, runOptions.attachOutput, containerOutputProcessors)
+		containerOutputDone = startContainerOutputPipeline(ctx, outputReader, sinks)
 	}
 
 	// Start container
-	fmt.Println("\n> Starting container with the latest image")
-	fmt.Println("> Container ID:", creationResponse.ID)
+	output.Println("\n> Starting container with the latest image")
+	output.Println("> Container ID:", creationResponse.ID)
+	output.Emit(output.Event{Event: output.EventContainer, ContainerId: creationResponse.ID, Image: image})
+	runlog.DefaultInstance.Printf("Starting container: %s", creationResponse.ID)
 	if err := client.ContainerStart(ctx, creationResponse.ID, types.ContainerStartOptions{}); err != nil {
 		return err
 	}
 
 	// Setup interrupt fns if enabled
+	var interruptSignal os.Signal
+	interruptLock := sync.Mutex{}
 	if runOptions.setupInterrupt {
 		// Listen for interrupt, clear signal after execution
-		// Remove container when received
-		// All cleanup here: The process ends after this
-		// and defer functions are not executed
-		sgn := utils.RunOnCtrlC(func() {
-			fmt.Println("\n> Received interrupt signal")
-			fmt.Println("> Terminating..")
+		// First signal: stop the container gracefully, so the engine can
+		// write what it has. Second signal: remove the container forcefully.
+		// In both cases the wait below returns, and RunImage returns an
+		// InterruptedError, so the deferred cleanup is executed as usual
+		sgn := utils.RunOnCtrlC(func(sgn os.Signal) {
+			interruptLock.Lock()
+			interruptSignal = sgn
+			interruptLock.Unlock()
+
+			output.Println("\n> Received interrupt signal")
+			output.Printf("> Stopping the scan engine (waiting up to %s). Interrupt again to terminate immediately..\n", containerStopGracePeriod)
+			runlog.DefaultInstance.Printf("Received interrupt signal (%s), stopping container", sgn)
+			telemetry.DefaultInstance.RecordAtomicMetric("interrupt", sgn)
+			StopContainer(client, ctx, creationResponse.ID, containerStopGracePeriod)
+		}, func(sgn os.Signal) {
+			output.Println("\n> Received another interrupt signal")
+			output.Println("> Terminating..")
+			runlog.DefaultInstance.Printf("Received another interrupt signal (%s), removing container", sgn)
 			RemoveContainerForcefully(client, ctx, creationResponse.ID)
 		})
 		defer utils.ClearSignals(sgn)
 	}
 
 	// Image output after this point
-	fmt.Println("\n> Waiting for process to complete:")
+	output.Println("\n> Waiting for process to complete:")
+	output.Emit(output.Event{Event: output.EventPhaseStart, Phase: "engine.run", ContainerId: creationResponse.ID})
+	if phaseTracker != nil {
+		phaseTracker.startPhase(0)
+	}
 
 	// wait for container to stop (automatically or by interrupt)
-	if err := WaitForContainer(client, ctx, creationResponse.ID); err != nil {
+	statusCode, err := WaitForContainer(client, ctx, creationResponse.ID)
+
+	// the output stream ends with the container, wait for the
+	// remaining output to be processed (bounded, to never hang)
+	if containerOutputDone != nil {
+		select {
+		case <-containerOutputDone:
+		case <-time.After(containerOutputDrainTimeout):
+			runlog.DefaultInstance.Printf("Timed out waiting for container output to be processed")
+		}
+	}
+	if phaseTracker != nil {
+		phaseTracker.stop()
+	}
+
+	// an interrupted container may also be removed while waiting
+	interruptLock.Lock()
+	receivedInterruptSignal := interruptSignal
+	interruptLock.Unlock()
+	if receivedInterruptSignal != nil {
+		runlog.DefaultInstance.Printf("Container stopped after interrupt (status code: %d)", statusCode)
+		return &InterruptedError{Signal: receivedInterruptSignal}
+	}
+
+	if err != nil {
+		runlog.DefaultInstance.Printf("Error waiting for container: %v", err)
 		return err
 	}
+	runlog.DefaultInstance.Printf("Container exited with status code: %d", statusCode)
+	output.Emit(output.Event{Event: output.EventPhaseEnd, Phase: "engine.run", ContainerId: creationResponse.ID, ExitStatus: output.ExitStatus(statusCode)})
+
+	if errorClassifier != nil {
+		if engineError := errorClassifier.getError(statusCode); engineError != nil {
+			runlog.DefaultInstance.Printf("Classified engine error: %s (%s)", engineError.Class.Id, engineError)
+			return engineError
+		}
+	}
+
+	if statusCode != 0 {
+		return &ContainerExitError{StatusCode: statusCode}
+	}
 
 	return nil
 }
+
+var urlCredentialsRegex = regexp.MustCompile(`://([^:/@\s]+):([^@\s]+)@`)
+
+// records the resolved container configuration in the run log
+func logContainerConfiguration(containerConfig *container.Config, hostConfig *container.HostConfig) {
+	if runlog.DefaultInstance == nil {
+		return
+	}
+
+	mounts := []string{}
+	for _, mount := range hostConfig.Mounts {
+		mountString := fmt.Sprintf("%s -> %s", mount.Source, mount.Target)
+		if mount.ReadOnly {
+			mountString += " (read-only)"
+		}
+		mounts = append(mounts, mountString)
+	}
+
+	runlog.DefaultInstance.LogSection("Container configuration", []string{
+		fmt.Sprintf("Image: %s", containerConfig.Image),
+		fmt.Sprintf("Entrypoint: %s", strings.Join(containerConfig.Entrypoint, " ")),
+		fmt.Sprintf("Cmd: %s", strings.Join(containerConfig.Cmd, " ")),
+		fmt.Sprintf("Tty: %t, OpenStdin: %t", containerConfig.Tty, containerConfig.OpenStdin),
+	})
+	// proxy credentials are not logged
+	environment := []string{}
+	for _, envVar := range containerConfig.Env {
+		environment = append(environment, urlCredentialsRegex.ReplaceAllString(envVar, "://${1}:****@"))
+	}
+	runlog.DefaultInstance.LogSection("Container environment", environment)
+	runlog.DefaultInstance.LogSection("Container mounts", mounts)
+}
@@ -0,0 +1,189 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package docker
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+)
+
+// this directive is necessary to load file in this variable;
+// it will also include the file automatically during build
+// ref about embed directive: https://pkg.go.dev/embed@master
+//
+//go:embed errors.json
+var engineErrorCatalogueFile []byte
+
+// A known class of engine failures, identified by
+// patterns in the container output or the exit status
+type EngineErrorClass struct {
+	Id          string `json:"id"`
+	Description string `json:"description"`
+
+	// regular expressions matched against each line of the container output
+	Patterns []string `json:"patterns"`
+
+	// container exit statuses that identify the class (without any output)
+	ExitStatuses []int64 `json:"exitStatuses"`
+
+	Remediation string `json:"remediation"`
+
+	// exit code for the CLI when the run fails with this class
+	ExitCode int `json:"exitCode"`
+
+	compiledPatterns []*regexp.Regexp
+}
+
+// exit code for classes without a valid exit code
+const defaultEngineErrorExitCode = 1
+
+// Returned by RunImage when the engine failure is classified
+type EngineError struct {
+	Class      *EngineErrorClass
+	Message    string
+	StatusCode int64
+}
+
+func (e *EngineError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%s: %s", e.Class.Description, e.Message)
+	}
+	return e.Class.Description
+}
+
+// Loads the embedded catalogue of engine errors, extended by the user
+// catalogue (if it exists). User classes override embedded classes with
+// the same id and are matched first. Invalid user catalogues or patterns
+// are skipped with a returned warning, so the classification is still usable
+func LoadEngineErrorCatalogue(userCataloguePath string) ([]*EngineErrorClass, []string) {
+	warnings := []string{}
+
+	catalogue := []*EngineErrorClass{}
+	if err := json.Unmarshal(engineErrorCatalogueFile, &catalogue); err != nil {
+		warnings = append(warnings, fmt.Sprintf("Could not parse embedded error catalogue: %v", err))
+	}
+
+	if userCataloguePath != "" {
+		if data, err := os.ReadFile(userCataloguePath); err == nil {
+			userCatalogue := []*EngineErrorClass{}
+			if err := json.Unmarshal(data, &userCatalogue); err != nil {
+				warnings = append(warnings, fmt.Sprintf("Could not parse error catalogue (%s): %v", userCataloguePath, err))
+			} else {
+				userClassIds := map[string]bool{}
+				for _, class := range userCatalogue {
+					userClassIds[class.Id] = true
+				}
+				for _, class := range catalogue {
+					if !userClassIds[class.Id] {
+						userCatalogue = append(userCatalogue, class)
+					}
+				}
+				catalogue = userCatalogue
+			}
+		} else if !os.IsNotExist(err) {
+			warnings = append(warnings, fmt.Sprintf("Could not read error catalogue (%s): %v", userCataloguePath, err))
+		}
+	}
+
+	for _, class := range catalogue {
+		// failed runs must never exit with success
+		if class.ExitCode < 1 || class.ExitCode > 255 {
+			warnings = append(warnings, fmt.Sprintf("Invalid exit code for error class %s: %d (must be 1-255), using %d", class.Id, class.ExitCode, defaultEngineErrorExitCode))
+			class.ExitCode = defaultEngineErrorExitCode
+		}
+
+		for _, pattern := range class.Patterns {
+			compiledPattern, err := regexp.Compile(pattern)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("Skipping invalid pattern for error class %s: %v", class.Id, err))
+				continue
+			}
+			class.compiledPatterns = append(class.compiledPatterns, compiledPattern)
+		}
+	}
+
+	return catalogue, warnings
+}
+
+// Classifies the engine failure from the container output and exit status.
+// The first matched line is retained, as later errors are usually a consequence
+type engineErrorClassifier struct {
+	catalogue    []*EngineErrorClass
+	matchedClass *EngineErrorClass
+	matchedLine  string
+	lock         sync.Mutex
+}
+
+func newEngineErrorClassifier(catalogue []*EngineErrorClass) *engineErrorClassifier {
+	return &engineErrorClassifier{catalogue: catalogue}
+}
+
+func (c *engineErrorClassifier) getOutputSink() containerOutputSink {
+	return func(line containerOutputLine) {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		if c.matchedClass != nil {
+			return
+		}
+
+		for _, class := range c.catalogue {
+			for _, pattern := range class.compiledPatterns {
+				if pattern.MatchString(line.text) {
+					c.matchedClass = class
+					c.matchedLine = line.text
+					return
+				}
+			}
+		}
+	}
+}
+
+// returns the classified error, or nil if the run is not identified as failed
+// Runs are only classified when the container exited non-zero (or was killed),
+// as the patterns may also match non-fatal output of a successful run
+func (c *engineErrorClassifier) getError(statusCode int64) *EngineError {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if statusCode == 0 {
+		return nil
+	}
+
+	if c.matchedClass != nil {
+		return &EngineError{Class: c.matchedClass, Message: c.matchedLine, StatusCode: statusCode}
+	}
+
+	for _, class := range c.catalogue {
+		for _, exitStatus := range class.ExitStatuses {
+			if exitStatus == statusCode {
+				return &EngineError{Class: class, StatusCode: statusCode}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEngineErrorClassifierGetError(t *testing.T) {
+	catalogue, warnings := LoadEngineErrorCatalogue("")
+	if len(warnings) > 0 {
+		t.Fatalf("embedded catalogue: %v", warnings)
+	}
+
+	tests := []struct {
+		name       string
+		lines      []string
+		statusCode int64
+		classId    string
+	}{
+		{"success without matches", []string{"scan complete"}, 0, ""},
+		{"success with a matching line", []string{"Cannot allocate memory", "scan complete"}, 0, ""},
+		{"failure with a matching line", []string{"java.lang.OutOfMemoryError: Java heap space"}, 1, "out-of-memory"},
+		{"killed without output", []string{}, 137, "out-of-memory"},
+		{"failure without matches", []string{"unknown failure"}, 1, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			classifier := newEngineErrorClassifier(catalogue)
+			sink := classifier.getOutputSink()
+			for _, line := range test.lines {
+				sink(containerOutputLine{raw: line + "\n", text: line})
+			}
+
+			engineError := classifier.getError(test.statusCode)
+			switch {
+			case test.classId == "" && engineError != nil:
+				t.Errorf("got %s, want no classified error", engineError.Class.Id)
+			case test.classId != "" && engineError == nil:
+				t.Errorf("got no classified error, want %s", test.classId)
+			case test.classId != "" && engineError.Class.Id != test.classId:
+				t.Errorf("got %s, want %s", engineError.Class.Id, test.classId)
+			}
+		})
+	}
+}
+
+func TestLoadEngineErrorCatalogueExitCode(t *testing.T) {
+	userCataloguePath := filepath.Join(t.TempDir(), "errors.json")
+	userCatalogue := `[
+		{"id": "no-exit-code", "description": "No exit code", "patterns": ["first"]},
+		{"id": "invalid-exit-code", "description": "Invalid exit code", "patterns": ["second"], "exitCode": 256},
+		{"id": "valid-exit-code", "description": "Valid exit code", "patterns": ["third"], "exitCode": 42}
+	]`
+	if err := os.WriteFile(userCataloguePath, []byte(userCatalogue), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	catalogue, warnings := LoadEngineErrorCatalogue(userCataloguePath)
+	if len(warnings) != 2 {
+		t.Errorf("got warnings %v, want 2 warnings", warnings)
+	}
+
+	exitCodes := map[string]int{}
+	for _, class := range catalogue {
+		exitCodes[class.Id] = class.ExitCode
+	}
+	want := map[string]int{
+		"no-exit-code":      defaultEngineErrorExitCode,
+		"invalid-exit-code": defaultEngineErrorExitCode,
+		"valid-exit-code":   42,
+		"out-of-memory":     3,
+	}
+	for id, exitCode := range want {
+		if exitCodes[id] != exitCode {
+			t.Errorf("%s: got exit code %d, want %d", id, exitCodes[id], exitCode)
+		}
+	}
+
+	// a failed run of a class without an exit code does not exit with success
+	classifier := newEngineErrorClassifier(catalogue)
+	classifier.getOutputSink()(containerOutputLine{raw: "first\n", text: "first"})
+	engineError := classifier.getError(1)
+	if engineError == nil || engineError.Class.ExitCode == 0 {
+		t.Errorf("got %v, want a classified error with a non-zero exit code", engineError)
+	}
+}
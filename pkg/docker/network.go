@@ -0,0 +1,117 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package docker
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+const javaToolOptionsEnvKey = "JAVA_TOOL_OPTIONS"
+
+// returns the JVM system properties for the proxy of the scheme (http, https)
+func getJavaProxyOptions(scheme, proxy string) []string {
+	if proxy == "" {
+		return nil
+	}
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+
+	proxyURL, err := url.Parse(proxy)
+	if err != nil || proxyURL.Hostname() == "" {
+		return nil
+	}
+
+	port := proxyURL.Port()
+	if port == "" {
+		port = map[string]string{"https": "443"}[proxyURL.Scheme]
+		if port == "" {
+			port = "80"
+		}
+	}
+
+	return []string{
+		fmt.Sprintf("-D%s.proxyHost=%s", scheme, proxyURL.Hostname()),
+		fmt.Sprintf("-D%s.proxyPort=%s", scheme, port),
+	}
+}
+
+// converts NO_PROXY (comma separated, .domain for subdomains) to http.nonProxyHosts (| separated, *.domain)
+func getJavaNonProxyHosts(noProxy string) string {
+	hosts := []string{}
+	for _, host := range strings.Split(noProxy, ",") {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		if strings.HasPrefix(host, ".") {
+			host = "*" + host
+		}
+		hosts = append(hosts, host)
+	}
+	return strings.Join(hosts, "|")
+}
+
+// Returns whether the proxy is on the loopback interface of the host,
+// which is not reachable from the container
+func IsLoopbackProxy(proxy string) bool {
+	if proxy == "" {
+		return false
+	}
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return false
+	}
+	if proxyURL.Hostname() == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(proxyURL.Hostname())
+	return ip != nil && ip.IsLoopback()
+}
+
+// Returns the environment for the container, with the network configuration
+// (see OptionWithProxy, OptionWithCABundle). The JVM options for the network
+// are prepended to JAVA_TOOL_OPTIONS, so that the options of the user take precedence
+func getContainerEnvironment(rh runImageHandler) []string {
+	environment := append([]string{}, rh.environmentVars...)
+	environment = append(environment, rh.networkEnvironmentVars...)
+	if len(rh.javaToolOptions) == 0 {
+		return environment
+	}
+
+	javaToolOptions := strings.Join(rh.javaToolOptions, " ")
+	for i, envVar := range environment {
+		if strings.HasPrefix(envVar, javaToolOptionsEnvKey+"=") {
+			environment[i] = fmt.Sprintf("%s=%s %s", javaToolOptionsEnvKey, javaToolOptions, strings.TrimPrefix(envVar, javaToolOptionsEnvKey+"="))
+			return environment
+		}
+	}
+	return append(environment, fmt.Sprintf("%s=%s", javaToolOptionsEnvKey, javaToolOptions))
+}
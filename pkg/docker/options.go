@@ -24,20 +24,23 @@ package docker
 
 import (
 	"fmt"
+	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/Privado-Inc/privado-cli/pkg/config"
+	"github.com/Privado-Inc/privado-cli/pkg/output"
 	"github.com/Privado-Inc/privado-cli/pkg/telemetry"
 )
 
 type containerVolumes struct {
 	userKeyVolumeEnabled, dockerKeyVolumeEnabled, sourceCodeVolumeEnabled,
 	externalRulesVolumeEnabled, userConfigVolumeEnabled, m2PackageCacheVolumeEnabled,
-	gradlePackageCacheVolumeEnabled bool
+	gradlePackageCacheVolumeEnabled, certificatesVolumeEnabled bool
 
 	userKeyVolumeHost, dockerKeyVolumeHost, sourceCodeVolumeHost,
 	externalRulesVolumeHost, userConfigVolumeHost, m2PackageCacheVolumeHost,
-	gradlePackageCacheVolumeHost string
+	gradlePackageCacheVolumeHost, certificatesVolumeHost string
 }
 
 type EnvVar struct {
@@ -52,12 +55,18 @@ type runImageHandler struct {
 	args                                []string
 	volumes                             containerVolumes
 	environmentVars                     []string
+	networkEnvironmentVars              []string
+	javaToolOptions                     []string
 	setupInterrupt                      bool
 	attachOutput                        bool
 	spawnWebBrowserOnURLMessage         bool
 	spawnWebBrowserOnURLTriggerMessages []string
+	disableBrowser                      bool
+	dashboardURLFilePath                string
 	exitOnError                         bool
 	exitOnErrorTriggerMessages          []string
+	showProgress                        bool
+	errorCatalogue                      []*EngineErrorClass
 }
 
 func newRunImageHandler(opts []RunImageOption) runImageHandler {
@@ -137,7 +146,8 @@ func OptionWithPackageCacheVolumes() RunImageOption {
 				}
 			} else {
 				warningMsg := fmt.Sprintf("Could not get package cache directory for pkg %s. skipping volume mount: %v", pkg, err)
-				fmt.Println("[WARN]: ", warningMsg)
+				output.Println("[WARN]: ", warningMsg)
+				output.Emit(output.Event{Event: output.EventWarning, Message: warningMsg})
 				telemetry.DefaultInstance.RecordArrayMetric("warning", warningMsg)
 			}
 		}
@@ -206,6 +216,20 @@ func OptionWithAutoSpawnBrowserOnURLMessages(messages []string) RunImageOption {
 	}
 }
 
+// the URL is still emitted and saved in the results metadata, but no browser is spawned
+func OptionWithDisabledBrowser(disableBrowser bool) RunImageOption {
+	return func(rh *runImageHandler) {
+		rh.disableBrowser = disableBrowser
+	}
+}
+
+// additionally writes the URL from the messages (see OptionWithAutoSpawnBrowserOnURLMessages) to the file
+func OptionWithDashboardURLFile(filePath string) RunImageOption {
+	return func(rh *runImageHandler) {
+		rh.dashboardURLFilePath = filePath
+	}
+}
+
 func OptionWithExitErrorMessages(messages []string) RunImageOption {
 	return func(rh *runImageHandler) {
 		rh.exitOnError = true
@@ -213,6 +237,22 @@ func OptionWithExitErrorMessages(messages []string) RunImageOption {
 	}
 }
 
+// renders the progress of the engine phases identified from the
+// container output, along with the elapsed time for each phase
+func OptionWithProgress() RunImageOption {
+	return func(rh *runImageHandler) {
+		rh.showProgress = true
+	}
+}
+
+// classifies engine failures using the catalogue (see LoadEngineErrorCatalogue)
+// RunImage returns an *EngineError when a failure is identified
+func OptionWithErrorClassification(catalogue []*EngineErrorClass) RunImageOption {
+	return func(rh *runImageHandler) {
+		rh.errorCatalogue = catalogue
+	}
+}
+
 func OptionWithDebug(isDebug bool) RunImageOption {
 	return func(rh *runImageHandler) {
 		// currently only enable output in debug mode
@@ -228,3 +268,57 @@ func OptionWithEntrypoint(entrypoint []string) RunImageOption {
 		rh.entrypoint = entrypoint
 	}
 }
+
+// Proxy for the requests of the engine (dependency download, upload)
+type ProxyConfiguration struct {
+	HTTPProxy, HTTPSProxy, NoProxy string
+}
+
+// Passes the proxy to the container, as environment variables (in both
+// cases, as tools differ) and as JVM system properties (JAVA_TOOL_OPTIONS),
+// which are used by the dependency resolution of Maven and Gradle
+func OptionWithProxy(proxy ProxyConfiguration) RunImageOption {
+	return func(rh *runImageHandler) {
+		for _, envVar := range []EnvVar{
+			{Key: "HTTP_PROXY", Value: proxy.HTTPProxy},
+			{Key: "HTTPS_PROXY", Value: proxy.HTTPSProxy},
+			{Key: "NO_PROXY", Value: proxy.NoProxy},
+		} {
+			if envVar.Value != "" {
+				rh.networkEnvironmentVars = append(rh.networkEnvironmentVars,
+					fmt.Sprintf("%s=%s", envVar.Key, envVar.Value),
+					fmt.Sprintf("%s=%s", strings.ToLower(envVar.Key), envVar.Value),
+				)
+			}
+		}
+
+		rh.javaToolOptions = append(rh.javaToolOptions, getJavaProxyOptions("http", proxy.HTTPProxy)...)
+		rh.javaToolOptions = append(rh.javaToolOptions, getJavaProxyOptions("https", proxy.HTTPSProxy)...)
+		if proxy.NoProxy != "" && (proxy.HTTPProxy != "" || proxy.HTTPSProxy != "") {
+			rh.javaToolOptions = append(rh.javaToolOptions, fmt.Sprintf("-Dhttp.nonProxyHosts=%s", getJavaNonProxyHosts(proxy.NoProxy)))
+		}
+	}
+}
+
+// Mounts the certificates directory (see PrepareContainerCertificates) and
+// configures the JVM truststore and node to trust the CA bundle
+func OptionWithCABundle(certificatesDirectory string) RunImageOption {
+	return func(rh *runImageHandler) {
+		if certificatesDirectory == "" {
+			return
+		}
+
+		rh.volumes.certificatesVolumeEnabled = true
+		rh.volumes.certificatesVolumeHost = certificatesDirectory
+
+		containerCertificatesDirectory := config.AppConfig.Container.CertificatesVolumeDir
+		rh.networkEnvironmentVars = append(rh.networkEnvironmentVars,
+			fmt.Sprintf("NODE_EXTRA_CA_CERTS=%s", path.Join(containerCertificatesDirectory, containerCABundleFilename)),
+		)
+		rh.javaToolOptions = append(rh.javaToolOptions,
+			fmt.Sprintf("-Djavax.net.ssl.trustStore=%s", path.Join(containerCertificatesDirectory, containerTrustStoreFilename)),
+			fmt.Sprintf("-Djavax.net.ssl.trustStorePassword=%s", containerTrustStorePassword),
+			"-Djavax.net.ssl.trustStoreType=JKS",
+		)
+	}
+}
@@ -0,0 +1,194 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/Privado-Inc/privado-cli/pkg/utils"
+)
+
+// The container output is read by a single reader and split into lines,
+// which are then fanned out, in order, to a set of sinks (stdout printer,
+// run log, output processors). Each sink runs in its own goroutine with a
+// bounded buffer, so a slow sink cannot reorder lines for others and only
+// blocks the reader once its buffer is full. The pipeline terminates when
+// the output reaches EOF (container exits) or the context is cancelled
+
+// number of lines buffered for each sink before the reader blocks
+const containerOutputSinkBufferSize = 256
+
+// lines longer than this are split, so that a single line
+// without a terminator cannot grow the buffer indefinitely
+const maxContainerOutputLineLength = 512 * 1024
+
+type containerOutputProcessor struct {
+	messages []string
+	matchFn  func(string)
+}
+
+type containerOutputLine struct {
+	// line as received, including its terminator
+	raw string
+
+	// line without the terminator and ANSI escape sequences
+	text string
+}
+
+type containerOutputSink func(line containerOutputLine)
+
+// bufio.SplitFunc for container output. Lines are terminated by "\n",
+// "\r\n" or a lone "\r": with a TTY, progress is rendered by rewriting
+// the current line using carriage returns, and each rewrite is a line
+// for the output processors. Tokens retain their terminator, so that the
+// output can be printed as received
+func scanContainerOutputLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i+1], nil
+		}
+		// carriage return: check if it is part of "\r\n"
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i+2], nil
+			}
+			return i + 1, data[:i+1], nil
+		}
+		if atEOF {
+			return len(data), data, nil
+		}
+		// request more data to decide
+		return 0, nil, nil
+	}
+
+	if atEOF || len(data) >= maxContainerOutputLineLength {
+		return len(data), data, nil
+	}
+
+	// request more data
+	return 0, nil, nil
+}
+
+func newContainerOutputScanner(reader io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 2*maxContainerOutputLineLength)
+	scanner.Split(scanContainerOutputLines)
+	return scanner
+}
+
+// Starts reading the container output and fanning out lines to the sinks.
+// The returned channel is closed once all lines are read and processed
+func startContainerOutputPipeline(ctx context.Context, reader io.Reader, sinks []containerOutputSink) <-chan struct{} {
+	done := make(chan struct{})
+	waitGroup := sync.WaitGroup{}
+
+	sinkChannels := []chan containerOutputLine{}
+	for _, sink := range sinks {
+		sinkChannel := make(chan containerOutputLine, containerOutputSinkBufferSize)
+		sinkChannels = append(sinkChannels, sinkChannel)
+
+		waitGroup.Add(1)
+		go func(sink containerOutputSink, sinkChannel chan containerOutputLine) {
+			defer waitGroup.Done()
+			for line := range sinkChannel {
+				sink(line)
+			}
+		}(sink, sinkChannel)
+	}
+
+	go func() {
+		defer close(done)
+
+		// lines are read separately, so that a cancellation is not blocked
+		// by a read without output (the reader is closed by the caller)
+		lines := make(chan containerOutputLine)
+		stopped := make(chan struct{})
+		go func() {
+			defer close(lines)
+
+			scanner := newContainerOutputScanner(reader)
+			for scanner.Scan() {
+				raw := scanner.Text()
+				line := containerOutputLine{
+					raw:  raw,
+					text: utils.StripANSIEscapeSequences(strings.TrimRight(raw, "\r\n")),
+				}
+
+				select {
+				case lines <- line:
+				case <-stopped:
+					return
+				}
+			}
+		}()
+
+	readLoop:
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					break readLoop
+				}
+				for _, sinkChannel := range sinkChannels {
+					select {
+					case sinkChannel <- line:
+					case <-ctx.Done():
+						break readLoop
+					}
+				}
+			case <-ctx.Done():
+				break readLoop
+			}
+		}
+		close(stopped)
+
+		// sinks drain the already buffered lines before exiting
+		for _, sinkChannel := range sinkChannels {
+			close(sinkChannel)
+		}
+		waitGroup.Wait()
+	}()
+
+	return done
+}
+
+// returns a sink that calls the processor for lines containing any of its messages
+func newContainerOutputProcessorSink(outputProcessor containerOutputProcessor) containerOutputSink {
+	return func(line containerOutputLine) {
+		for _, message := range outputProcessor.messages {
+			if strings.Contains(line.text, message) {
+				outputProcessor.matchFn(strings.TrimSpace(line.text))
+				return
+			}
+		}
+	}
+}
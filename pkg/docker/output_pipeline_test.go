@@ -0,0 +1,205 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package docker
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func scanAllContainerOutputLines(t *testing.T, reader io.Reader) []string {
+	t.Helper()
+
+	tokens := []string{}
+	scanner := newContainerOutputScanner(reader)
+	for scanner.Scan() {
+		tokens = append(tokens, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	return tokens
+}
+
+func TestScanContainerOutputLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		tokens []string
+	}{
+		{"empty", "", []string{}},
+		{"newline", "one\ntwo\n", []string{"one\n", "two\n"}},
+		{"carriage return and newline", "one\r\ntwo\r\n", []string{"one\r\n", "two\r\n"}},
+		{"lone carriage return", "10%\r20%\rdone\n", []string{"10%\r", "20%\r", "done\n"}},
+		{"mixed terminators", "a\r\nb\rc\n", []string{"a\r\n", "b\r", "c\n"}},
+		{"empty lines", "\n\r\n\r", []string{"\n", "\r\n", "\r"}},
+		{"eof without terminator", "one\ntwo", []string{"one\n", "two"}},
+		{"eof after carriage return", "one\r", []string{"one\r"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// the one byte reader places a buffer boundary after every byte
+			// (e.g. between "\r" and "\n")
+			readers := map[string]io.Reader{
+				"whole":    strings.NewReader(test.input),
+				"one byte": iotest.OneByteReader(strings.NewReader(test.input)),
+			}
+			for readerName, reader := range readers {
+				if tokens := scanAllContainerOutputLines(t, reader); !reflect.DeepEqual(tokens, test.tokens) {
+					t.Errorf("%s reader: got %q, want %q", readerName, tokens, test.tokens)
+				}
+			}
+		})
+	}
+}
+
+func TestScanContainerOutputLinesCarriageReturnAtBufferBoundary(t *testing.T) {
+	// more data is required to decide between "\r" and "\r\n"
+	advance, token, err := scanContainerOutputLines([]byte("progress\r"), false)
+	if advance != 0 || token != nil || err != nil {
+		t.Errorf("got (%d, %q, %v), want a request for more data", advance, token, err)
+	}
+
+	advance, token, _ = scanContainerOutputLines([]byte("progress\r\nnext"), false)
+	if advance != 10 || string(token) != "progress\r\n" {
+		t.Errorf("got (%d, %q), want (10, \"progress\\r\\n\")", advance, token)
+	}
+
+	advance, token, _ = scanContainerOutputLines([]byte("progress\rnext"), false)
+	if advance != 9 || string(token) != "progress\r" {
+		t.Errorf("got (%d, %q), want (9, \"progress\\r\")", advance, token)
+	}
+}
+
+func TestScanContainerOutputLinesOverLongLine(t *testing.T) {
+	input := strings.Repeat("a", maxContainerOutputLineLength+10) + "\n"
+
+	tokens := scanAllContainerOutputLines(t, strings.NewReader(input))
+	if len(tokens) != 2 {
+		t.Fatalf("got %d tokens, want the line split in 2", len(tokens))
+	}
+	for _, token := range tokens {
+		if len(token) > maxContainerOutputLineLength {
+			t.Errorf("got a token of %d bytes, want at most %d", len(token), maxContainerOutputLineLength)
+		}
+	}
+	if strings.Join(tokens, "") != input {
+		t.Errorf("split line does not add up to the input")
+	}
+}
+
+// collects the lines received by a sink
+type lineCollector struct {
+	lock  sync.Mutex
+	lines []string
+}
+
+func (c *lineCollector) sink(line containerOutputLine) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.lines = append(c.lines, line.text)
+}
+
+func (c *lineCollector) getLines() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]string{}, c.lines...)
+}
+
+func waitForContainerOutputPipeline(t *testing.T, done <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pipeline did not stop")
+	}
+}
+
+func TestContainerOutputPipelineOrder(t *testing.T) {
+	expectedLines := []string{}
+	input := strings.Builder{}
+	// more lines than the sink buffers, with a slow sink
+	for i := 0; i < 3*containerOutputSinkBufferSize; i++ {
+		line := strings.Repeat("x", i%7) + string(rune('a'+i%26))
+		expectedLines = append(expectedLines, line)
+		input.WriteString("\x1b[32m" + line + "\x1b[0m\r\n")
+	}
+
+	fastCollector, slowCollector := &lineCollector{}, &lineCollector{}
+	slowSink := func(line containerOutputLine) {
+		if len(slowCollector.getLines())%100 == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		slowCollector.sink(line)
+	}
+
+	done := startContainerOutputPipeline(context.Background(), strings.NewReader(input.String()), []containerOutputSink{fastCollector.sink, slowSink})
+	waitForContainerOutputPipeline(t, done)
+
+	for name, collector := range map[string]*lineCollector{"fast": fastCollector, "slow": slowCollector} {
+		if lines := collector.getLines(); !reflect.DeepEqual(lines, expectedLines) {
+			t.Errorf("%s sink: got %d lines, want %d lines in order (without terminators and ANSI escape sequences)", name, len(lines), len(expectedLines))
+		}
+	}
+}
+
+func TestContainerOutputPipelineStopsOnEOF(t *testing.T) {
+	reader, writer := io.Pipe()
+	collector := &lineCollector{}
+	done := startContainerOutputPipeline(context.Background(), reader, []containerOutputSink{collector.sink})
+
+	io.WriteString(writer, "one\ntwo\n")
+	select {
+	case <-done:
+		t.Fatal("pipeline stopped before EOF")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	writer.Close()
+	waitForContainerOutputPipeline(t, done)
+
+	if lines := collector.getLines(); !reflect.DeepEqual(lines, []string{"one", "two"}) {
+		t.Errorf("got %q, want [one two]", lines)
+	}
+}
+
+func TestContainerOutputPipelineStopsOnCancel(t *testing.T) {
+	// no output and no EOF: the read blocks until the pipeline is cancelled
+	reader, writer := io.Pipe()
+	defer writer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	collector := &lineCollector{}
+	done := startContainerOutputPipeline(ctx, reader, []containerOutputSink{collector.sink})
+
+	io.WriteString(writer, "one\n")
+	cancel()
+	waitForContainerOutputPipeline(t, done)
+}
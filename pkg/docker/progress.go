@@ -0,0 +1,173 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package docker
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Privado-Inc/privado-cli/pkg/output"
+	"github.com/Privado-Inc/privado-cli/pkg/runlog"
+	"github.com/Privado-Inc/privado-cli/pkg/telemetry"
+	"github.com/Privado-Inc/privado-cli/pkg/utils"
+	"github.com/moby/term"
+)
+
+type enginePhase struct {
+	// identifier used for json events
+	id string
+
+	// human readable name rendered in the progress
+	name string
+
+	// engine output messages marking the start of the phase (we use strings.Contains)
+	markers []string
+}
+
+// phases of an engine scan, in the order they are executed
+var enginePhases = []enginePhase{
+	{
+		id:      "engine.start",
+		name:    "Starting scan engine",
+		markers: []string{},
+	},
+	{
+		id:      "engine.dependencies",
+		name:    "Downloading dependencies",
+		markers: []string{"Dependency resolution", "Downloading dependencies", "dependency download"},
+	},
+	{
+		id:      "engine.cpg",
+		name:    "Building code property graph",
+		markers: []string{"Parsing source code", "Building CPG", "CPG generation"},
+	},
+	{
+		id:      "engine.tagging",
+		name:    "Tagging source code with rules",
+		markers: []string{"Tagging source code", "Tagging the source code"},
+	},
+	{
+		id:      "engine.flows",
+		name:    "Computing data flows",
+		markers: []string{"Finding source to sink flow", "Computing flows", "Finding flows"},
+	},
+	{
+		id:      "engine.report",
+		name:    "Writing report",
+		markers: []string{"Brewing result", "Exporting output", "Writing report"},
+	},
+}
+
+// Tracks the current engine phase from the container output
+// and drives the progress display (text) or phase events (json)
+type enginePhaseTracker struct {
+	progress     *utils.PhaseProgress
+	currentPhase int
+	lock         sync.Mutex
+
+	// phases are recorded as children of the engine span
+	engineSpan *telemetry.Span
+	phaseSpan  *telemetry.Span
+}
+
+func newEnginePhaseTracker(engineSpan *telemetry.Span) *enginePhaseTracker {
+	tracker := &enginePhaseTracker{currentPhase: -1, engineSpan: engineSpan}
+	if !output.IsJSON() {
+		tracker.progress = utils.NewPhaseProgress(output.TextWriter(), output.IsInteractive() && term.IsTerminal(os.Stdout.Fd()))
+	}
+	return tracker
+}
+
+func (t *enginePhaseTracker) getOutputProcessor() containerOutputProcessor {
+	markers := []string{}
+	for _, phase := range enginePhases {
+		markers = append(markers, phase.markers...)
+	}
+
+	return containerOutputProcessor{
+		messages: markers,
+		matchFn: func(message string) {
+			for i, phase := range enginePhases {
+				for _, marker := range phase.markers {
+					if strings.Contains(message, marker) {
+						t.startPhase(i)
+						return
+					}
+				}
+			}
+		},
+	}
+}
+
+// phases only move forward: markers of current or earlier phases are ignored
+func (t *enginePhaseTracker) startPhase(phaseIndex int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if phaseIndex <= t.currentPhase {
+		return
+	}
+
+	t.endPhase()
+	t.currentPhase = phaseIndex
+	phase := enginePhases[phaseIndex]
+	runlog.DefaultInstance.Printf("Engine phase: %s", phase.name)
+	output.Emit(output.Event{Event: output.EventPhaseStart, Phase: phase.id, Message: phase.name})
+	if t.engineSpan != nil {
+		t.phaseSpan = t.engineSpan.StartChildSpan(phase.id)
+	}
+	if t.progress != nil {
+		t.progress.StartPhase(phase.name)
+	}
+}
+
+// callers must hold the lock
+func (t *enginePhaseTracker) endPhase() {
+	if t.currentPhase < 0 || t.currentPhase >= len(enginePhases) {
+		return
+	}
+	phase := enginePhases[t.currentPhase]
+	output.Emit(output.Event{Event: output.EventPhaseEnd, Phase: phase.id, Message: phase.name})
+	if t.phaseSpan != nil {
+		t.phaseSpan.Finish(nil)
+	}
+}
+
+// prints the container output line without breaking the progress display
+func (t *enginePhaseTracker) print(line string) {
+	if t.progress != nil {
+		t.progress.Print(line)
+	} else {
+		output.Print(line)
+	}
+}
+
+func (t *enginePhaseTracker) stop() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.endPhase()
+	t.currentPhase = len(enginePhases)
+	if t.progress != nil {
+		t.progress.Stop()
+	}
+}
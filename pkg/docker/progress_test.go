@@ -0,0 +1,92 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// the fixture is an excerpt of the engine output: phases are identified from
+// its wording, update the fixture (and markers) when the engine output changes
+func readEngineOutputFixture(t *testing.T) []string {
+	data, err := os.ReadFile(filepath.Join("testdata", "engine_output.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+}
+
+// feeds the lines to the phase tracker, and returns the ids of the started phases
+func getEnginePhasesForOutput(lines []string) []string {
+	tracker := &enginePhaseTracker{currentPhase: -1}
+	sink := newContainerOutputProcessorSink(tracker.getOutputProcessor())
+
+	phases := []string{}
+	for _, line := range lines {
+		currentPhase := tracker.currentPhase
+		sink(containerOutputLine{raw: line + "\n", text: line})
+		if tracker.currentPhase != currentPhase {
+			phases = append(phases, enginePhases[tracker.currentPhase].id)
+		}
+	}
+	return phases
+}
+
+func TestEnginePhasesFromEngineOutput(t *testing.T) {
+	phases := getEnginePhasesForOutput(readEngineOutputFixture(t))
+
+	// the start phase is started with the container, not from the output
+	want := []string{}
+	for _, phase := range enginePhases[1:] {
+		want = append(want, phase.id)
+	}
+
+	if strings.Join(phases, ",") != strings.Join(want, ",") {
+		t.Errorf("got phases %v, want %v", phases, want)
+	}
+}
+
+func TestEnginePhasesOnlyMoveForward(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  []string
+	}{
+		{"no markers", []string{"Scanning directory: /app/code"}, []string{}},
+		{"skipped phases", []string{"Parsing source code..", "Brewing result.."}, []string{"engine.cpg", "engine.report"}},
+		{"earlier phase markers", []string{"Tagging source code with rules..", "Downloading dependencies", "Building CPG"}, []string{"engine.tagging"}},
+		{"repeated markers", []string{"Building CPG for java", "Building CPG for python"}, []string{"engine.cpg"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			phases := getEnginePhasesForOutput(test.lines)
+			if strings.Join(phases, ",") != strings.Join(test.want, ",") {
+				t.Errorf("got phases %v, want %v", phases, test.want)
+			}
+		})
+	}
+}
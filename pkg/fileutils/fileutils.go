@@ -25,6 +25,8 @@ package fileutils
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -104,6 +106,21 @@ func GetPathToCurrentBinary() (string, error) {
 	return resolvedFilePath, nil
 }
 
+// returns the SHA-256 digest of the file contents (hex encoded)
+func GetSHA256ChecksumOfFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
 func ExtractTarGzFile(sourceFile, target string) error {
 	ctx := context.Background()
 	data, err := ioutil.ReadFile(sourceFile)
@@ -42,3 +42,14 @@ func HasWritePermissionToFile(filePath string) (bool, error) {
 
 	return true, nil
 }
+
+// returns the disk space (bytes) available to unprivileged users
+// on the filesystem containing the path
+func GetAvailableDiskSpace(path string) (uint64, error) {
+	stat := syscall.Statfs_t{}
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
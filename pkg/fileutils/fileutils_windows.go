@@ -27,6 +27,8 @@ import (
 	"errors"
 	"io/fs"
 	"os"
+	"syscall"
+	"unsafe"
 )
 
 // yields error on unix-based systems after upgrades
@@ -44,3 +46,26 @@ func HasWritePermissionToFile(filePath string) (bool, error) {
 
 	return true, nil
 }
+
+// returns the disk space (bytes) available to the user
+// on the volume containing the path
+func GetAvailableDiskSpace(path string) (uint64, error) {
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var freeBytesAvailable, totalBytes, totalFreeBytes uint64
+	getDiskFreeSpaceEx := syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+	result, _, err := getDiskFreeSpaceEx.Call(
+		uintptr(unsafe.Pointer(pathPtr)),
+		uintptr(unsafe.Pointer(&freeBytesAvailable)),
+		uintptr(unsafe.Pointer(&totalBytes)),
+		uintptr(unsafe.Pointer(&totalFreeBytes)),
+	)
+	if result == 0 {
+		return 0, err
+	}
+
+	return freeBytesAvailable, nil
+}
@@ -0,0 +1,173 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The package decides how user facing output is rendered for the run.
+// In the (default) text format, messages are printed as they are and
+// events are ignored. In the json format, messages are dropped and
+// events are written as newline-delimited JSON, so the output can be
+// consumed by other tools without parsing human readable text
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Event types emitted in the json format
+const (
+	EventPhaseStart   = "phase.start"
+	EventPhaseEnd     = "phase.end"
+	EventImage        = "image"
+	EventContainer    = "container"
+	EventEngineLog    = "engine.log"
+	EventDashboardURL = "dashboard.url"
+	EventResult       = "result"
+	EventReport       = "report"
+	EventWarning      = "warning"
+	EventError        = "error"
+	EventExit         = "exit"
+)
+
+var currentFormat = FormatText
+
+// when not interactive, output must not require a terminal:
+// no prompts, spinners, or ANSI escape sequences
+var interactive = true
+var writer io.Writer = os.Stdout
+var lock sync.Mutex
+
+type Event struct {
+	Time        string `json:"time"`
+	Event       string `json:"event"`
+	Phase       string `json:"phase,omitempty"`
+	Message     string `json:"message,omitempty"`
+	Image       string `json:"image,omitempty"`
+	Digest      string `json:"digest,omitempty"`
+	ContainerId string `json:"containerId,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Path        string `json:"path,omitempty"`
+	ErrorClass  string `json:"errorClass,omitempty"`
+	Remediation string `json:"remediation,omitempty"`
+	ExitStatus  *int64 `json:"exitStatus,omitempty"`
+
+	// diagnostics report (doctor)
+	Report interface{} `json:"report,omitempty"`
+}
+
+func SetFormat(outputFormat string) error {
+	switch outputFormat {
+	case FormatText, FormatJSON:
+		lock.Lock()
+		defer lock.Unlock()
+		currentFormat = outputFormat
+		return nil
+	}
+
+	return fmt.Errorf("unsupported output format: %s (supported: %s)", outputFormat, strings.Join([]string{FormatText, FormatJSON}, ", "))
+}
+
+func IsJSON() bool {
+	lock.Lock()
+	defer lock.Unlock()
+	return currentFormat == FormatJSON
+}
+
+func SetInteractive(isInteractive bool) {
+	lock.Lock()
+	defer lock.Unlock()
+	interactive = isInteractive
+}
+
+func IsInteractive() bool {
+	lock.Lock()
+	defer lock.Unlock()
+	return interactive
+}
+
+// Returns the writer for human readable output
+// discards everything in the json format
+func TextWriter() io.Writer {
+	if IsJSON() {
+		return io.Discard
+	}
+	return writer
+}
+
+// Print, Printf and Println behave like their fmt counterparts
+// in the text format and are ignored in the json format
+func Print(a ...interface{}) {
+	if !IsJSON() {
+		fmt.Fprint(writer, a...)
+	}
+}
+
+func Printf(format string, a ...interface{}) {
+	if !IsJSON() {
+		fmt.Fprintf(writer, format, a...)
+	}
+}
+
+func Println(a ...interface{}) {
+	if !IsJSON() {
+		fmt.Fprintln(writer, a...)
+	}
+}
+
+// Writes the event as a single JSON line in the json format
+// and is ignored in the text format
+func Emit(event Event) {
+	if !IsJSON() {
+		return
+	}
+
+	event.Time = time.Now().UTC().Format(time.RFC3339Nano)
+
+	// encoder terminates each event with a newline
+	// html escaping is disabled to keep messages and urls readable
+	eventBuffer := &bytes.Buffer{}
+	encoder := json.NewEncoder(eventBuffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(event); err != nil {
+		return
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	writer.Write(eventBuffer.Bytes())
+}
+
+// Helper to pass exit status values in events
+func ExitStatus(status int64) *int64 {
+	return &status
+}
@@ -0,0 +1,261 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package runlog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Privado-Inc/privado-cli/pkg/config"
+)
+
+// Similar to telemetry, a singular run log instance is maintained
+// in the package itself, so that it can be updated from anywhere
+// during the run. DefaultInstance is nil until a run log is initiated
+// and all methods are safe to be called on a nil instance
+var DefaultInstance *RunLog
+
+const runLogFileExtension = ".log"
+
+// number of most recent run logs retained in the run log directory
+const runLogRetentionCount = 50
+
+type RunLog struct {
+	Id   string
+	Path string
+
+	files  []*os.File
+	closed bool
+	lock   sync.Mutex
+}
+
+type RunLogEntry struct {
+	Id      string
+	Path    string
+	ModTime time.Time
+	Size    int64
+}
+
+// returns the directory where per-run logs are stored
+// empty when the privado state directory is not available
+func GetRunLogDirectory() string {
+	if config.AppConfig.StateDirectory == "" {
+		return ""
+	}
+	return filepath.Join(config.AppConfig.StateDirectory, "logs")
+}
+
+// Creates a new run log in the run log directory. If logFilePath is
+// specified, the log is additionally written to that file
+func InitiateRunLog(logFilePath string) (*RunLog, error) {
+	runLog := &RunLog{
+		Id: fmt.Sprintf("%s-%s", time.Now().Format("20060102-150405"), strings.Split(config.UserConfig.SessionId, "-")[0]),
+	}
+
+	if runLogDirectory := GetRunLogDirectory(); runLogDirectory != "" {
+		if err := os.MkdirAll(runLogDirectory, os.ModePerm); err == nil {
+			runLog.Path = filepath.Join(runLogDirectory, runLog.Id+runLogFileExtension)
+			if file, err := os.OpenFile(runLog.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600); err == nil {
+				runLog.files = append(runLog.files, file)
+			}
+			pruneRunLogs(runLogRetentionCount)
+		}
+	}
+
+	if logFilePath != "" {
+		file, err := os.OpenFile(logFilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			runLog.closeFiles()
+			return nil, err
+		}
+		runLog.files = append(runLog.files, file)
+		if runLog.Path == "" {
+			runLog.Path = logFilePath
+		}
+	}
+
+	if len(runLog.files) == 0 {
+		return nil, fmt.Errorf("run log directory is not available")
+	}
+
+	return runLog, nil
+}
+
+// Writes a timestamped line to the run log
+func (r *RunLog) Printf(format string, args ...interface{}) {
+	if r == nil {
+		return
+	}
+	r.writeLine("", fmt.Sprintf(format, args...))
+}
+
+// Writes a timestamped line of container output to the run log
+func (r *RunLog) LogContainerOutput(line string) {
+	if r == nil {
+		return
+	}
+	r.writeLine("[engine] ", strings.TrimRight(line, "\r\n"))
+}
+
+// Writes a titled section with each value on its own indented line
+func (r *RunLog) LogSection(title string, values []string) {
+	if r == nil {
+		return
+	}
+	r.writeLine("", title+":")
+	for _, value := range values {
+		r.writeLine("", "    "+value)
+	}
+}
+
+// Records the exit status and closes the run log
+// Subsequent calls are ignored, so the first recorded status is kept
+func (r *RunLog) Close(exitStatus int) {
+	if r == nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed {
+		return
+	}
+
+	r.write("", fmt.Sprintf("Exit status: %d", exitStatus))
+	r.closed = true
+	r.closeFiles()
+}
+
+func (r *RunLog) writeLine(prefix, msg string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.closed {
+		return
+	}
+	r.write(prefix, msg)
+}
+
+// callers must hold the lock
+func (r *RunLog) write(prefix, msg string) {
+	line := fmt.Sprintf("[%s] %s%s\n", time.Now().Format(time.RFC3339Nano), prefix, msg)
+	for _, file := range r.files {
+		// ignore errors: logging should never interrupt the run
+		_, _ = io.WriteString(file, line)
+	}
+}
+
+func (r *RunLog) closeFiles() {
+	for _, file := range r.files {
+		file.Close()
+	}
+	r.files = nil
+}
+
+// Lists all available run logs, most recent first
+func ListRunLogs() ([]RunLogEntry, error) {
+	runLogDirectory := GetRunLogDirectory()
+	if runLogDirectory == "" {
+		return nil, fmt.Errorf("run log directory is not available")
+	}
+
+	files, err := os.ReadDir(runLogDirectory)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []RunLogEntry{}, nil
+		}
+		return nil, err
+	}
+
+	entries := []RunLogEntry{}
+	for _, file := range files {
+		if file.IsDir() || filepath.Ext(file.Name()) != runLogFileExtension {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, RunLogEntry{
+			Id:      strings.TrimSuffix(file.Name(), runLogFileExtension),
+			Path:    filepath.Join(runLogDirectory, file.Name()),
+			ModTime: info.ModTime(),
+			Size:    info.Size(),
+		})
+	}
+
+	// ids are prefixed with a timestamp, so sorting them is chronological
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Id > entries[j].Id
+	})
+
+	return entries, nil
+}
+
+// Returns the run log entry for the specified id
+func GetRunLog(id string) (*RunLogEntry, error) {
+	entries, err := ListRunLogs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.Id == id {
+			return &entry, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no run log found with id: %s", id)
+}
+
+// Returns the most recent run log entry
+func GetLastRunLog() (*RunLogEntry, error) {
+	entries, err := ListRunLogs()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no run logs found")
+	}
+
+	return &entries[0], nil
+}
+
+// removes the oldest run logs, keeping the specified count
+func pruneRunLogs(keep int) {
+	entries, err := ListRunLogs()
+	if err != nil || len(entries) <= keep {
+		return
+	}
+
+	for _, entry := range entries[keep:] {
+		// ignore errors: logs are removed again on the next run
+		os.Remove(entry.Path)
+	}
+}
@@ -0,0 +1,85 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package signature
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+)
+
+// Public keys for release signatures, compiled into the binary
+// These keys are trusted to sign release archives and the key list
+//
+//go:embed release_keys.pub
+var releaseKeys []byte
+
+func GetReleaseKeys() ([]PublicKey, error) {
+	keys, err := ParsePublicKeys(releaseKeys)
+	if err != nil {
+		return nil, fmt.Errorf("embedded release keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Returns whether release keys are embedded in this build. Builds without
+// release keys (until the key is provisioned) cannot verify signatures
+func HasReleaseKeys() bool {
+	keys, err := GetReleaseKeys()
+	return err == nil && len(keys) > 0
+}
+
+// Returns the keys trusted to sign release archives: the release keys and,
+// for key rotation, the keys of a key list signed with a release key
+// The key list (and signature) are optional, pass nil when not available
+func GetTrustedKeys(keyList, keyListSignature []byte) ([]PublicKey, error) {
+	trustedKeys, err := GetReleaseKeys()
+	if err != nil {
+		return nil, err
+	}
+	if len(trustedKeys) == 0 {
+		return nil, fmt.Errorf("no release keys are embedded in this build")
+	}
+
+	if keyList == nil {
+		return trustedKeys, nil
+	}
+	if keyListSignature == nil {
+		return nil, fmt.Errorf("key list is not signed")
+	}
+
+	signature, err := ParseSignature(keyListSignature)
+	if err != nil {
+		return nil, fmt.Errorf("key list signature: %w", err)
+	}
+	// only the release keys can sign the key list
+	if _, err := signature.Verify(bytes.NewReader(keyList), trustedKeys); err != nil {
+		return nil, fmt.Errorf("key list: %w", err)
+	}
+
+	listedKeys, err := ParsePublicKeys(keyList)
+	if err != nil {
+		return nil, fmt.Errorf("key list: %w", err)
+	}
+	return append(trustedKeys, listedKeys...), nil
+}
@@ -0,0 +1,200 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package signature
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Detached signatures in the minisign format
+// ref: https://jedisct1.github.io/minisign/
+
+const (
+	untrustedCommentPrefix = "untrusted comment:"
+	trustedCommentPrefix   = "trusted comment: "
+
+	keyIdLength = 8
+)
+
+var (
+	// signs the contents of the file
+	algorithmEd25519 = [2]byte{'E', 'd'}
+	// signs the BLAKE2b-512 digest of the file (default for minisign >= 0.10)
+	algorithmEd25519Prehashed = [2]byte{'E', 'D'}
+)
+
+var ErrUntrustedKey = errors.New("signed with an untrusted key")
+
+type KeyId [keyIdLength]byte
+
+// formatted as minisign prints key ids (hex of the little-endian integer)
+func (id KeyId) String() string {
+	return fmt.Sprintf("%016X", binary.LittleEndian.Uint64(id[:]))
+}
+
+type PublicKey struct {
+	KeyId KeyId
+	Key   ed25519.PublicKey
+}
+
+type Signature struct {
+	Algorithm       [2]byte
+	KeyId           KeyId
+	Signature       []byte
+	TrustedComment  string
+	GlobalSignature []byte
+}
+
+// Parses minisign public keys, one base64 encoded key per line
+// Empty lines, untrusted comments and lines starting with '#' are ignored
+func ParsePublicKeys(data []byte) ([]PublicKey, error) {
+	keys := []PublicKey{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, untrustedCommentPrefix) {
+			continue
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(line)
+		if err != nil || len(decoded) != 2+keyIdLength+ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid public key: %s", line)
+		}
+		if !bytes.Equal(decoded[:2], algorithmEd25519[:]) {
+			return nil, fmt.Errorf("unsupported public key algorithm: %s", line)
+		}
+
+		key := PublicKey{Key: ed25519.PublicKey(decoded[2+keyIdLength:])}
+		copy(key.KeyId[:], decoded[2:2+keyIdLength])
+		keys = append(keys, key)
+	}
+
+	return keys, scanner.Err()
+}
+
+// Parses a minisign signature file (.minisig)
+func ParseSignature(data []byte) (*Signature, error) {
+	lines := strings.Split(strings.TrimSpace(strings.ReplaceAll(string(data), "\r\n", "\n")), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], untrustedCommentPrefix) || !strings.HasPrefix(lines[2], trustedCommentPrefix) {
+		return nil, errors.New("invalid signature file")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(decoded) != 2+keyIdLength+ed25519.SignatureSize {
+		return nil, errors.New("invalid signature")
+	}
+
+	globalSignature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(globalSignature) != ed25519.SignatureSize {
+		return nil, errors.New("invalid trusted comment signature")
+	}
+
+	signature := &Signature{
+		Signature:       decoded[2+keyIdLength:],
+		TrustedComment:  strings.TrimPrefix(lines[2], trustedCommentPrefix),
+		GlobalSignature: globalSignature,
+	}
+	copy(signature.Algorithm[:], decoded[:2])
+	copy(signature.KeyId[:], decoded[2:2+keyIdLength])
+
+	if signature.Algorithm != algorithmEd25519 && signature.Algorithm != algorithmEd25519Prehashed {
+		return nil, fmt.Errorf("unsupported signature algorithm: %s", signature.Algorithm[:])
+	}
+
+	return signature, nil
+}
+
+// Verifies the signature (and its trusted comment) of the content with
+// the matching trusted key, and returns the key used for verification
+func (s *Signature) Verify(content io.Reader, trustedKeys []PublicKey) (*PublicKey, error) {
+	var key *PublicKey
+	for i := range trustedKeys {
+		if trustedKeys[i].KeyId == s.KeyId {
+			key = &trustedKeys[i]
+			break
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%w (key id: %s)", ErrUntrustedKey, s.KeyId)
+	}
+
+	var message []byte
+	if s.Algorithm == algorithmEd25519Prehashed {
+		hash, err := blake2b.New512(nil)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(hash, content); err != nil {
+			return nil, err
+		}
+		message = hash.Sum(nil)
+	} else {
+		var err error
+		if message, err = io.ReadAll(content); err != nil {
+			return nil, err
+		}
+	}
+
+	if !ed25519.Verify(key.Key, message, s.Signature) {
+		return nil, fmt.Errorf("signature verification failed (key id: %s)", s.KeyId)
+	}
+
+	// the trusted comment is signed together with the signature
+	if !ed25519.Verify(key.Key, append(append([]byte{}, s.Signature...), s.TrustedComment...), s.GlobalSignature) {
+		return nil, fmt.Errorf("trusted comment verification failed (key id: %s)", s.KeyId)
+	}
+
+	return key, nil
+}
+
+// trusted comment of release asset signatures (see .github/workflows/release.yaml)
+func GetReleaseTrustedComment(releaseTag, filename string) string {
+	return fmt.Sprintf("privado-cli %s %s", releaseTag, filename)
+}
+
+// Verifies the signature of a release asset, and that it was signed for the
+// release and asset, so that a signed asset of another release (e.g. an older
+// one) cannot be substituted
+func (s *Signature) VerifyReleaseAsset(content io.Reader, trustedKeys []PublicKey, releaseTag, filename string) (*PublicKey, error) {
+	key, err := s.Verify(content, trustedKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	// the trusted comment is authenticated by Verify
+	if expected := GetReleaseTrustedComment(releaseTag, filename); s.TrustedComment != expected {
+		return nil, fmt.Errorf("signature is for '%s', expected '%s'", s.TrustedComment, expected)
+	}
+	return key, nil
+}
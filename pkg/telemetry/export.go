@@ -0,0 +1,182 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/Privado-Inc/privado-cli/pkg/utils"
+)
+
+// OTLP/JSON encoding of spans (ExportTraceServiceRequest)
+// ref: https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type otlpTraceRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceId           string          `json:"traceId"`
+	SpanId            string          `json:"spanId"`
+	ParentSpanId      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string             `json:"key"`
+	Value otlpAttributeValue `json:"value"`
+}
+
+type otlpAttributeValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusCodeOk     = 1
+	otlpStatusCodeError  = 2
+)
+
+const otlpServiceName = "privado-cli"
+
+func getOTLPAttributes(attributes map[string]string) []otlpAttribute {
+	keys := []string{}
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	otlpAttributes := []otlpAttribute{}
+	for _, key := range keys {
+		otlpAttributes = append(otlpAttributes, otlpAttribute{Key: key, Value: otlpAttributeValue{StringValue: attributes[key]}})
+	}
+	return otlpAttributes
+}
+
+// returns the spans encoded as an OTLP/JSON trace request
+func (t *Tracer) GetOTLPRequestBody(spans []*Span, version string) ([]byte, error) {
+	otlpSpans := []otlpSpan{}
+	for _, span := range spans {
+		status := otlpStatus{Code: otlpStatusCodeOk}
+		if span.Err != nil {
+			status = otlpStatus{Code: otlpStatusCodeError, Message: span.Err.Error()}
+		}
+
+		otlpSpans = append(otlpSpans, otlpSpan{
+			TraceId:           t.traceId,
+			SpanId:            span.SpanId,
+			ParentSpanId:      span.ParentSpanId,
+			Name:              span.Name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        getOTLPAttributes(span.Attributes),
+			Status:            status,
+		})
+	}
+
+	return json.Marshal(otlpTraceRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{Attributes: getOTLPAttributes(map[string]string{
+				"service.name":    otlpServiceName,
+				"service.version": version,
+			})},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: otlpServiceName, Version: version},
+				Spans: otlpSpans,
+			}},
+		}},
+	})
+}
+
+// Appends the trace request as a line to the file (OTLP JSON lines, as
+// written by the file exporter of the OpenTelemetry collector)
+func ExportOTLPToFile(requestBody []byte, path string) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(requestBody, '\n'))
+	return err
+}
+
+// Posts the trace request to an OTLP/HTTP collector endpoint (e.g. http://localhost:4318/v1/traces)
+// bounded by telemetryDeliveryTimeout, as the command exits afterwards
+func ExportOTLPToEndpoint(requestBody []byte, url string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryDeliveryTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	res, err := utils.DoHTTPRequest(req, utils.HTTPRequestOptions{Timeout: telemetryDeliveryTimeout})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("received non-ok status from collector: %d", res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Privado-Inc/privado-cli/pkg/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// the command exits after this time, even if the telemetry is not delivered
+	telemetryDeliveryTimeout = 3 * time.Second
+
+	// events that are not delivered are retried on later invocations
+	// with an exponential backoff, until they expire
+	telemetryQueueMaxEvents   = 50
+	telemetryQueueMaxAge      = 7 * 24 * time.Hour
+	telemetryRetryBaseBackoff = time.Minute
+	telemetryRetryMaxBackoff  = 12 * time.Hour
+)
+
+// An event that could not be delivered, stored as a file in the queue directory
+type queuedTelemetryEvent struct {
+	Url                   string          `json:"url"`
+	AuthenticationKeyHash string          `json:"authenticationKeyHash"`
+	RequestBody           json.RawMessage `json:"requestBody"`
+	CreatedAt             time.Time       `json:"createdAt"`
+	Attempts              int             `json:"attempts"`
+	NextAttemptAt         time.Time       `json:"nextAttemptAt"`
+
+	path string
+}
+
+func postTelemetryRequest(ctx context.Context, url, authenticationKeyHash string, requestBody []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("Authentication", authenticationKeyHash)
+	req.Header.Add("Content-Type", "application/json")
+
+	res, err := utils.DoHTTPRequest(req, utils.HTTPRequestOptions{Timeout: telemetryDeliveryTimeout})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 201 {
+		return fmt.Errorf("received non-ok status from telemetry: %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+func getTelemetryRetryBackoff(attempts int) time.Duration {
+	backoff := telemetryRetryBaseBackoff
+	for i := 1; i < attempts && backoff < telemetryRetryMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > telemetryRetryMaxBackoff {
+		backoff = telemetryRetryMaxBackoff
+	}
+	return backoff
+}
+
+// writes the event to the queue directory (atomically, as other invocations may read the queue)
+func saveQueuedTelemetryEvent(event *queuedTelemetryEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	temporaryPath := event.path + ".tmp"
+	if err := os.WriteFile(temporaryPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(temporaryPath, event.path)
+}
+
+func enqueueTelemetryEvent(queueDirectory, url, authenticationKeyHash string, requestBody []byte) error {
+	if err := os.MkdirAll(queueDirectory, os.ModePerm); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return saveQueuedTelemetryEvent(&queuedTelemetryEvent{
+		Url:                   url,
+		AuthenticationKeyHash: authenticationKeyHash,
+		RequestBody:           requestBody,
+		CreatedAt:             now,
+		Attempts:              1,
+		NextAttemptAt:         now.Add(getTelemetryRetryBackoff(1)),
+		path:                  filepath.Join(queueDirectory, fmt.Sprintf("%d-%s.json", now.UnixNano(), uuid.NewString())),
+	})
+}
+
+// Returns the queued events, oldest first. Expired, invalid and
+// excess (oldest) events are removed from the queue
+func loadTelemetryQueue(queueDirectory string) []*queuedTelemetryEvent {
+	entries, err := os.ReadDir(queueDirectory)
+	if err != nil {
+		return nil
+	}
+
+	events := []*queuedTelemetryEvent{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		path := filepath.Join(queueDirectory, entry.Name())
+		event := &queuedTelemetryEvent{path: path}
+		data, err := os.ReadFile(path)
+		if err == nil {
+			err = json.Unmarshal(data, event)
+		}
+		if err != nil || time.Since(event.CreatedAt) > telemetryQueueMaxAge {
+			os.Remove(path)
+			continue
+		}
+		events = append(events, event)
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].CreatedAt.Before(events[j].CreatedAt)
+	})
+	for len(events) > telemetryQueueMaxEvents {
+		os.Remove(events[0].path)
+		events = events[1:]
+	}
+
+	return events
+}
+
+// Retries the queued events that are due, until the context is done.
+// Retrying stops at the first failure, as the endpoint is likely unreachable
+func flushTelemetryQueue(ctx context.Context, queueDirectory string) {
+	for _, event := range loadTelemetryQueue(queueDirectory) {
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Now().Before(event.NextAttemptAt) {
+			continue
+		}
+
+		if err := postTelemetryRequest(ctx, event.Url, event.AuthenticationKeyHash, event.RequestBody); err != nil {
+			event.Attempts++
+			event.NextAttemptAt = time.Now().Add(getTelemetryRetryBackoff(event.Attempts))
+			saveQueuedTelemetryEvent(event)
+			return
+		}
+		os.Remove(event.path)
+	}
+}
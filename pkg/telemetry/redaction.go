@@ -0,0 +1,176 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package telemetry
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const redactedValue = "<redacted>"
+
+// Metrics are redacted when recorded, so that paths, usernames, URLs
+// and other user data never leave the machine. Values of the metrics
+// listed here are known to be safe and are sent as is; the command line
+// metrics and the environment only retain allow-listed values, and all
+// other metrics (e.g. warnings and errors) are redacted with redactText
+var metricRedactors = map[string]func(string) string{
+	"os":                         keepValue,
+	"arch":                       keepValue,
+	"version":                    keepValue,
+	"ci":                         keepValue,
+	"ciProvider":                 keepValue,
+	"didReceiveCloudLinkMessage": keepValue,
+	"didParseCloudLink":          keepValue,
+	"didAutoSpawnBrowser":        keepValue,
+	"errorClass":                 keepValue,
+	"interrupt":                  keepValue,
+	"phaseDurations":             keepValue,
+	"cmd":                        redactCommandLine,
+	"dockerCmd":                  redactCommandLine,
+	"env":                        redactEnvironmentVariable,
+}
+
+// commands and subcommands of Privado CLI, retained in the command line
+var allowedCommandWords = map[string]bool{
+	"scan": true, "upload": true, "validate": true, "config": true, "metrics": true,
+	"get": true, "set": true, "unset": true, "list": true, "reset": true, "profiles": true,
+	"doctor": true, "logs": true, "update": true, "version": true, "help": true, "completion": true,
+}
+
+// flags with values that are retained in the command line
+var allowedFlagValues = map[string]bool{
+	"--output-format": true,
+}
+
+// environment variables (for the image) with values that are retained
+var allowedEnvironmentVariables = map[string]bool{
+	"CI":                      true,
+	"PRIVADO_VERSION_CLI":     true,
+	"PRIVADO_SYNC_TO_CLOUD":   true,
+	"PRIVADO_METRICS_ENABLED": true,
+}
+
+var (
+	simpleValueRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	urlRegex         = regexp.MustCompile(`[A-Za-z][A-Za-z0-9+.-]*://[^\s'"<>]+`)
+	emailRegex       = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	unixPathRegex    = regexp.MustCompile(`(^|[\s'"(=:,\[])(~|\.{1,2})?/[^\s'"),:\]]*`)
+	windowsPathRegex = regexp.MustCompile(`(^|[\s'"(=:,\[])([A-Za-z]:\\|\\\\)[^\s'"),\]]*`)
+)
+
+// returns the redacted value of the metric, formatted as a string
+// slices are redacted per element, retaining the format of the value
+func redactMetric(key string, value interface{}) string {
+	redactor, exists := metricRedactors[key]
+	if !exists {
+		redactor = redactText
+	}
+
+	if values, isSlice := value.([]string); isSlice {
+		redactedValues := make([]string, len(values))
+		for i, value := range values {
+			redactedValues[i] = redactor(value)
+		}
+		return fmt.Sprintf("%v", redactedValues)
+	}
+
+	return redactor(fmt.Sprintf("%v", value))
+}
+
+func keepValue(value string) string {
+	return value
+}
+
+// retains the commands, flag names and allow-listed flag values;
+// the executable is reduced to its name, and other arguments are redacted
+func redactCommandLine(commandLine string) string {
+	tokens := strings.Fields(commandLine)
+	for i, token := range tokens {
+		switch {
+		case i == 0:
+			tokens[i] = redactText(filepath.Base(token))
+		case strings.HasPrefix(token, "-"):
+			if parts := strings.SplitN(token, "=", 2); len(parts) == 2 {
+				name, value := parts[0], parts[1]
+				if !allowedFlagValues[name] || !simpleValueRegex.MatchString(value) {
+					value = redactedValue
+				}
+				tokens[i] = fmt.Sprintf("%s=%s", name, value)
+			}
+		case allowedCommandWords[token]:
+		case allowedFlagValues[tokens[i-1]] && simpleValueRegex.MatchString(token):
+		default:
+			tokens[i] = redactedValue
+		}
+	}
+	return strings.Join(tokens, " ")
+}
+
+func redactEnvironmentVariable(envVar string) string {
+	key := strings.SplitN(envVar, "=", 2)[0]
+	if allowedEnvironmentVariables[key] {
+		return envVar
+	}
+	return fmt.Sprintf("%s=%s", key, redactedValue)
+}
+
+// redacts URLs, email addresses, paths and usernames in free text
+func redactText(text string) string {
+	text = urlRegex.ReplaceAllString(text, "<url>")
+	text = emailRegex.ReplaceAllString(text, "<email>")
+	text = unixPathRegex.ReplaceAllString(text, "${1}<path>")
+	text = windowsPathRegex.ReplaceAllString(text, "${1}<path>")
+	if usernameRegex := getUsernameRegex(); usernameRegex != nil {
+		text = usernameRegex.ReplaceAllString(text, "<user>")
+	}
+	return text
+}
+
+var (
+	usernameRegex     *regexp.Regexp
+	usernameRegexOnce sync.Once
+)
+
+// matches the username of the current user as a word;
+// very short usernames are not matched, as they are likely regular words
+func getUsernameRegex() *regexp.Regexp {
+	usernameRegexOnce.Do(func() {
+		username := ""
+		if currentUser, err := user.Current(); err == nil {
+			username = filepath.Base(currentUser.Username)
+		} else if username = os.Getenv("USER"); username == "" {
+			username = os.Getenv("USERNAME")
+		}
+
+		if len(username) >= 3 {
+			usernameRegex = regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(username) + `\b`)
+		}
+	})
+	return usernameRegex
+}
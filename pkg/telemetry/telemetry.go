@@ -23,11 +23,12 @@
 package telemetry
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
+	"os"
 	"runtime"
+	"sync"
 )
 
 // Note: current implementation is based on creating a telemetry instance
@@ -39,10 +40,15 @@ import (
 // creating a DefaultInstance for global updates
 var DefaultInstance = InitiateTelemetryInstance()
 
+// Telemetry is safe for concurrent use: metrics are recorded from the
+// container output goroutines, while the event may be sent on exit
 type Telemetry struct {
 	metricMap   map[string]interface{}
 	requestBody telemetryRequestBody
-	Recorded    bool
+
+	// set when the event is sent (or queued, or saved), so that it is sent exactly once
+	recorded bool
+	lock     sync.Mutex
 }
 
 type telemetryRequestBody struct {
@@ -54,25 +60,43 @@ type telemetryRequestBody struct {
 
 type TelemetryRequestConfig struct {
 	Url, UserHash, SessionId, AuthenticationKeyHash string
+
+	// undelivered telemetry is queued in this directory (optional)
+	QueueDirectory string
+}
+
+// A metric that can be recorded and sent with the telemetry event
+type Metric struct {
+	Key         string
+	Description string
+}
+
+// all metrics sent by Privado CLI (listed by `privado config metrics --list`);
+// metrics not listed here are never recorded
+var SupportedMetrics = []Metric{
+	{"os", "Operating system (e.g. linux, darwin, windows)"},
+	{"arch", "CPU architecture (e.g. amd64, arm64)"},
+	{"cmd", "Command line used to run Privado CLI, with paths and other argument values redacted"},
+	{"dockerCmd", "Command run in the privado image, with paths and other argument values redacted"},
+	{"version", "Version of Privado CLI"},
+	{"env", "Environment variables set for the privado image by Privado CLI, with values redacted (except CI, version and settings)"},
+	{"ci", "Whether Privado CLI is run in a CI environment"},
+	{"ciProvider", "Name of the CI provider (e.g. GitHub Actions)"},
+	{"didReceiveCloudLinkMessage", "Whether the privado image returned a dashboard link"},
+	{"didParseCloudLink", "Whether the dashboard link was parsed from the output"},
+	{"didAutoSpawnBrowser", "Whether the dashboard link was opened in a browser"},
+	{"warning", "Warning messages shown by Privado CLI, with paths, URLs and usernames redacted"},
+	{"error", "Error messages shown by Privado CLI, with paths, URLs and usernames redacted"},
+	{"errorClass", "Identifier of the classified scan engine failure (see errors.json)"},
+	{"interrupt", "Signal that interrupted the scan (e.g. interrupt)"},
+	{"phaseDurations", "Duration of each phase of the run (e.g. image.pull=5200ms)"},
 }
 
 func isSupportedMetric(key string) bool {
-	switch key {
-	case
-		"os",
-		"arch",
-		"cmd",
-		"dockerCmd",
-		"version",
-		"env",
-		"ci",
-		"ciProvider",
-		"didReceiveCloudLinkMessage",
-		"didParseCloudLink",
-		"didAutoSpawnBrowser",
-		"warning",
-		"error":
-		return true
+	for _, metric := range SupportedMetrics {
+		if metric.Key == key {
+			return true
+		}
 	}
 
 	return false
@@ -94,66 +118,141 @@ func InitiateTelemetryInstance() *Telemetry {
 }
 
 func (t *Telemetry) RecordAtomicMetric(key string, value interface{}) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
 	if isSupportedMetric(key) {
-		t.metricMap[key] = fmt.Sprintf("%v", value)
+		t.metricMap[key] = redactMetric(key, value)
 	}
 }
 
 func (t *Telemetry) RecordArrayMetric(key string, value interface{}) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
 	// perform only if supported metric
 	if isSupportedMetric(key) {
 		// if key exists, append value, else define value
 		if val, ok := t.metricMap[key]; ok {
 			// if already an array, append value, else transform value into an array with both values
 			if typedVal, isArray := val.([]string); isArray {
-				t.metricMap[key] = append(typedVal, fmt.Sprintf("%v", value))
+				t.metricMap[key] = append(typedVal, redactMetric(key, value))
 			} else {
-				t.metricMap[key] = []string{fmt.Sprintf("%v", val), fmt.Sprintf("%v", value)}
+				t.metricMap[key] = []string{fmt.Sprintf("%v", val), redactMetric(key, value)}
 			}
 		} else {
-			t.metricMap[key] = []string{fmt.Sprintf("%v", value)}
+			t.metricMap[key] = []string{redactMetric(key, value)}
 		}
 	}
 }
 
+// returns a copy of the recorded metrics
 func (t *Telemetry) GetRecordedMetrics() map[string]interface{} {
-	return t.metricMap
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	metrics := map[string]interface{}{}
+	for key, value := range t.metricMap {
+		if values, isArray := value.([]string); isArray {
+			value = append([]string{}, values...)
+		}
+		metrics[key] = value
+	}
+	return metrics
 }
 
-func (t *Telemetry) PostRecordedTelemetry(reqConfig TelemetryRequestConfig) error {
+// whether the event is already sent (or queued, or saved)
+func (t *Telemetry) IsRecorded() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.recorded
+}
+
+// marks the event as recorded before sending it, so that concurrent
+// callers do not send it again; returns false if it is already recorded
+func (t *Telemetry) claimRecording() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.recorded {
+		return false
+	}
+	t.recorded = true
+	return true
+}
+
+// when the event could not be sent, it can be sent again by a later call
+func (t *Telemetry) releaseRecording() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.recorded = false
+}
+
+// returns the exact request body sent by PostRecordedTelemetry
+func (t *Telemetry) GetRequestBody(reqConfig TelemetryRequestConfig) ([]byte, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
 	t.requestBody.UserHash = reqConfig.UserHash
 	t.requestBody.SessionId = reqConfig.SessionId
 
 	if metricsJson, err := json.MarshalIndent(t.metricMap, "", "    "); err != nil {
-		return err
+		return nil, err
 	} else {
 		t.requestBody.EventMessage = string(metricsJson)
 	}
 
-	requestBody, err := json.Marshal(t.requestBody)
-	if err != nil {
-		return err
+	return json.Marshal(t.requestBody)
+}
+
+// Saves the request body to a file, instead of posting it (to preview the telemetry)
+// The telemetry is considered recorded, so that it is not posted afterwards
+func (t *Telemetry) SaveRecordedTelemetry(reqConfig TelemetryRequestConfig, path string) error {
+	if !t.claimRecording() {
+		return nil
 	}
 
-	req, err := http.NewRequest("POST", reqConfig.Url, bytes.NewBuffer(requestBody))
+	requestBody, err := t.GetRequestBody(reqConfig)
+	if err == nil {
+		err = os.WriteFile(path, requestBody, 0644)
+	}
 	if err != nil {
+		t.releaseRecording()
 		return err
 	}
 
-	req.Header.Add("Authentication", reqConfig.AuthenticationKeyHash)
-	req.Header.Add("Content-Type", "application/json")
+	return nil
+}
+
+// Posts the telemetry, and retries the queued telemetry of earlier invocations
+// (independent of the delivery of this telemetry). Delivery is bounded by telemetryDeliveryTimeout; telemetry that is not
+// delivered is queued (in QueueDirectory, if specified) to be retried later
+// The event is sent once: later (or concurrent) calls do nothing
+func (t *Telemetry) PostRecordedTelemetry(reqConfig TelemetryRequestConfig) error {
+	if !t.claimRecording() {
+		return nil
+	}
 
-	res, err := http.DefaultClient.Do(req)
+	requestBody, err := t.GetRequestBody(reqConfig)
 	if err != nil {
+		t.releaseRecording()
 		return err
 	}
-	defer res.Body.Close()
 
-	if res.StatusCode != 201 {
-		return fmt.Errorf("received non-ok status from telemetry: %d", res.StatusCode)
+	ctx, cancel := context.WithTimeout(context.Background(), telemetryDeliveryTimeout)
+	defer cancel()
+
+	postErr := postTelemetryRequest(ctx, reqConfig.Url, reqConfig.AuthenticationKeyHash, requestBody)
+	if postErr != nil {
+		if reqConfig.QueueDirectory == "" || enqueueTelemetryEvent(reqConfig.QueueDirectory, reqConfig.Url, reqConfig.AuthenticationKeyHash, requestBody) != nil {
+			t.releaseRecording()
+		}
 	}
 
-	t.Recorded = true
+	// the queue is retried on every run (within the remaining delivery time),
+	// also when this event is not delivered; the new event is not yet due
+	if reqConfig.QueueDirectory != "" {
+		flushTelemetryQueue(ctx, reqConfig.QueueDirectory)
+	}
 
-	return nil
+	return postErr
 }
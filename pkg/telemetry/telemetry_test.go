@@ -0,0 +1,194 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package telemetry
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// run with `go test -race`
+func TestConcurrentRecordingAndPosting(t *testing.T) {
+	var posts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			atomic.AddInt32(&posts, 1)
+		}
+		w.WriteHeader(201)
+	}))
+	defer server.Close()
+
+	telemetryInstance := InitiateTelemetryInstance()
+	reqConfig := TelemetryRequestConfig{Url: server.URL, UserHash: "user", SessionId: "session"}
+
+	start := make(chan struct{})
+	waitGroup := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			<-start
+			for j := 0; j < 100; j++ {
+				telemetryInstance.RecordAtomicMetric("ci", j%2 == 0)
+				telemetryInstance.RecordArrayMetric("warning", "warning message")
+			}
+		}()
+	}
+
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			<-start
+			errs <- telemetryInstance.PostRecordedTelemetry(reqConfig)
+		}()
+	}
+
+	close(start)
+	waitGroup.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("post failed: %v", err)
+		}
+	}
+	if sent := atomic.LoadInt32(&posts); sent != 1 {
+		t.Errorf("got %d posts, want exactly 1", sent)
+	}
+	if !telemetryInstance.IsRecorded() {
+		t.Error("telemetry is not marked as recorded")
+	}
+
+	// later calls do not send the event again
+	if err := telemetryInstance.PostRecordedTelemetry(reqConfig); err != nil || atomic.LoadInt32(&posts) != 1 {
+		t.Errorf("got %d posts (err: %v) after a later call, want exactly 1", atomic.LoadInt32(&posts), err)
+	}
+}
+
+func TestPostRecordedTelemetryReleasesRecordingOnFailure(t *testing.T) {
+	status := int32(500)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer server.Close()
+
+	telemetryInstance := InitiateTelemetryInstance()
+	reqConfig := TelemetryRequestConfig{Url: server.URL}
+
+	// not queued (no queue directory), so it can be sent again
+	if err := telemetryInstance.PostRecordedTelemetry(reqConfig); err == nil {
+		t.Fatal("post succeeded, want the error status")
+	}
+	if telemetryInstance.IsRecorded() {
+		t.Fatal("failed telemetry is marked as recorded")
+	}
+
+	atomic.StoreInt32(&status, 201)
+	if err := telemetryInstance.PostRecordedTelemetry(reqConfig); err != nil {
+		t.Fatalf("post failed: %v", err)
+	}
+	if !telemetryInstance.IsRecorded() {
+		t.Error("telemetry is not marked as recorded")
+	}
+}
+
+func TestPostRecordedTelemetryFlushesQueueOnFailure(t *testing.T) {
+	var queuedPosts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "queued-event") {
+			atomic.AddInt32(&queuedPosts, 1)
+			w.WriteHeader(201)
+			return
+		}
+		// the event of this run is not delivered
+		w.WriteHeader(500)
+	}))
+	defer server.Close()
+
+	queueDirectory := t.TempDir()
+	queuedEventPath := filepath.Join(queueDirectory, "0-queued.json")
+	if err := saveQueuedTelemetryEvent(&queuedTelemetryEvent{
+		Url:           server.URL,
+		RequestBody:   json.RawMessage(`{"event":"queued-event"}`),
+		CreatedAt:     time.Now().Add(-time.Hour),
+		Attempts:      1,
+		NextAttemptAt: time.Now().Add(-time.Minute),
+		path:          queuedEventPath,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	telemetryInstance := InitiateTelemetryInstance()
+	reqConfig := TelemetryRequestConfig{Url: server.URL, QueueDirectory: queueDirectory}
+	if err := telemetryInstance.PostRecordedTelemetry(reqConfig); err == nil {
+		t.Fatal("post succeeded, want the error status")
+	}
+
+	if sent := atomic.LoadInt32(&queuedPosts); sent != 1 {
+		t.Errorf("got %d posts of the queued event, want 1", sent)
+	}
+	if _, err := os.Stat(queuedEventPath); !os.IsNotExist(err) {
+		t.Errorf("delivered event is still queued (err: %v)", err)
+	}
+	// the undelivered event of this run is queued instead
+	if events := loadTelemetryQueue(queueDirectory); len(events) != 1 {
+		t.Errorf("got %d queued events, want 1", len(events))
+	}
+}
+
+func TestGetRecordedMetricsReturnsCopy(t *testing.T) {
+	telemetryInstance := InitiateTelemetryInstance()
+	telemetryInstance.RecordArrayMetric("warning", "first")
+
+	metrics := telemetryInstance.GetRecordedMetrics()
+	metrics["warning"].([]string)[0] = "changed"
+	metrics["os"] = "changed"
+
+	body, err := telemetryInstance.GetRequestBody(TelemetryRequestConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestBody := telemetryRequestBody{}
+	if err := json.Unmarshal(body, &requestBody); err != nil {
+		t.Fatal(err)
+	}
+	recorded := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(requestBody.EventMessage), &recorded); err != nil {
+		t.Fatal(err)
+	}
+	if recorded["os"] == "changed" || recorded["warning"].([]interface{})[0] != "first" {
+		t.Errorf("changes to the returned metrics modified the recorded metrics: %v", recorded)
+	}
+}
@@ -0,0 +1,147 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package telemetry
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Note: spans record the duration of each phase of a run (e.g. image pull).
+// The durations are sent with the telemetry event (phaseDurations), and
+// can be exported as OTLP/JSON to a file or a collector (see export.go)
+
+// creating a DefaultTracer for global updates, similar to DefaultInstance
+var DefaultTracer = newTracer()
+
+type Tracer struct {
+	traceId  string
+	rootSpan *Span
+	spans    []*Span
+	finished bool
+	lock     sync.Mutex
+}
+
+type Span struct {
+	Name         string
+	SpanId       string
+	ParentSpanId string
+	Start        time.Time
+	End          time.Time
+	Attributes   map[string]string
+	Err          error
+
+	tracer *Tracer
+}
+
+func newTracer() *Tracer {
+	return &Tracer{traceId: newTraceIdentifier(16)}
+}
+
+func newTraceIdentifier(size int) string {
+	id := make([]byte, size)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Starts a span; the first span started is the root span (e.g. the command)
+// and is the parent of all other spans, unless started with StartChildSpan
+func StartSpan(name string) *Span {
+	return DefaultTracer.startSpan(name, nil)
+}
+
+func (t *Tracer) startSpan(name string, parent *Span) *Span {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	span := &Span{
+		Name:       name,
+		SpanId:     newTraceIdentifier(8),
+		Start:      time.Now(),
+		Attributes: map[string]string{},
+		tracer:     t,
+	}
+
+	if parent == nil {
+		parent = t.rootSpan
+	}
+	if parent != nil {
+		span.ParentSpanId = parent.SpanId
+	} else {
+		t.rootSpan = span
+	}
+
+	t.spans = append(t.spans, span)
+	return span
+}
+
+func (s *Span) StartChildSpan(name string) *Span {
+	return s.tracer.startSpan(name, s)
+}
+
+func (s *Span) SetAttribute(key string, value interface{}) {
+	s.tracer.lock.Lock()
+	defer s.tracer.lock.Unlock()
+	s.Attributes[key] = fmt.Sprintf("%v", value)
+}
+
+// Ends the span with the error of the phase (nil on success)
+// Spans can be ended only once, later calls are ignored
+func (s *Span) Finish(err error) {
+	s.tracer.lock.Lock()
+	defer s.tracer.lock.Unlock()
+	if !s.End.IsZero() {
+		return
+	}
+	s.End = time.Now()
+	s.Err = err
+}
+
+func (s *Span) Duration() time.Duration {
+	if s.End.IsZero() {
+		return 0
+	}
+	return s.End.Sub(s.Start)
+}
+
+// Finishes all spans that are not finished (e.g. the root span) with the
+// error of the run, and returns all spans. The durations are recorded as
+// phaseDurations in the telemetry instance, only the first time
+func (t *Tracer) FinishSpans(telemetryInstance *Telemetry, err error) []*Span {
+	t.lock.Lock()
+	spans := append([]*Span{}, t.spans...)
+	alreadyFinished := t.finished
+	t.finished = true
+	t.lock.Unlock()
+
+	for _, span := range spans {
+		span.Finish(err)
+		if !alreadyFinished && telemetryInstance != nil {
+			telemetryInstance.RecordArrayMetric("phaseDurations", fmt.Sprintf("%s=%dms", span.Name, span.Duration().Milliseconds()))
+		}
+	}
+	return spans
+}
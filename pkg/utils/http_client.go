@@ -0,0 +1,172 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/Privado-Inc/privado-cli/pkg/config"
+)
+
+// Note: all outbound requests (GitHub releases, downloads, telemetry) use
+// a single client, created on first use, which honours the proxy environment
+// (HTTPS_PROXY, HTTP_PROXY, NO_PROXY) and the additional CA bundle (see
+// config.GetCABundlePath), and sets a versioned User-Agent
+
+type HTTPRequestOptions struct {
+	// the request, including reading the response body, is cancelled
+	// after the timeout; 0 for no timeout (the request context still applies)
+	Timeout time.Duration
+
+	// retries for idempotent requests (GET, HEAD) that fail with a
+	// network error or a retryable status (429, 5xx), with backoff
+	Retries int
+}
+
+var DefaultHTTPRequestOptions = HTTPRequestOptions{Timeout: 30 * time.Second, Retries: 2}
+
+const httpRetryBaseBackoff = 500 * time.Millisecond
+
+var (
+	httpClient     *http.Client
+	httpClientErr  error
+	httpClientOnce sync.Once
+
+	httpUserAgent = getHTTPUserAgent("dev")
+)
+
+func getHTTPUserAgent(version string) string {
+	return fmt.Sprintf("privado-cli/%s (%s; %s)", version, runtime.GOOS, runtime.GOARCH)
+}
+
+func SetHTTPUserAgentVersion(version string) {
+	httpUserAgent = getHTTPUserAgent(version)
+}
+
+// returns the shared client; errors (e.g. an invalid CA bundle) are returned for every request
+func getHTTPClient() (*http.Client, error) {
+	httpClientOnce.Do(func() {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = http.ProxyFromEnvironment
+		transport.TLSHandshakeTimeout = 10 * time.Second
+		transport.ResponseHeaderTimeout = 30 * time.Second
+
+		if caBundlePath := config.GetCABundlePath(); caBundlePath != "" {
+			rootCAs, err := getRootCAsWithBundle(caBundlePath)
+			if err != nil {
+				httpClientErr = err
+				return
+			}
+			transport.TLSClientConfig = &tls.Config{RootCAs: rootCAs}
+		}
+
+		httpClient = &http.Client{Transport: transport}
+	})
+	return httpClient, httpClientErr
+}
+
+// the system certificates, extended by the certificates in the bundle
+func getRootCAsWithBundle(caBundlePath string) (*x509.CertPool, error) {
+	bundle, err := os.ReadFile(caBundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read CA bundle: %w", err)
+	}
+
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil || rootCAs == nil {
+		rootCAs = x509.NewCertPool()
+	}
+	if !rootCAs.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("no PEM certificates found in CA bundle: %s", caBundlePath)
+	}
+	return rootCAs, nil
+}
+
+// cancels the request timeout when the response body is closed
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+func isIdempotentHTTPMethod(method string) bool {
+	return method == "" || method == http.MethodGet || method == http.MethodHead
+}
+
+func isRetryableHTTPStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// Sends the request with the shared client. The caller must close the response body
+func DoHTTPRequest(req *http.Request, opts HTTPRequestOptions) (*http.Response, error) {
+	client, err := getHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", httpUserAgent)
+	}
+
+	retries := opts.Retries
+	if !isIdempotentHTTPMethod(req.Method) {
+		retries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := req.Context(), context.CancelFunc(func() {})
+		if opts.Timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		}
+
+		response, err := client.Do(req.Clone(ctx))
+		retryable := err != nil || isRetryableHTTPStatus(response.StatusCode)
+		if !retryable || attempt >= retries || req.Context().Err() != nil {
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			response.Body = &cancelOnCloseBody{ReadCloser: response.Body, cancel: cancel}
+			return response, nil
+		}
+
+		if response != nil {
+			response.Body.Close()
+		}
+		cancel()
+		time.Sleep(httpRetryBaseBackoff << attempt)
+	}
+}
@@ -0,0 +1,51 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package utils
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// returns the total and available memory (bytes) of the host
+// available memory counts free and purgeable pages (a lower bound)
+func GetHostMemory() (total uint64, available uint64, err error) {
+	total, err = unix.SysctlUint64("hw.memsize")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	pageSize, err := unix.SysctlUint32("hw.pagesize")
+	if err != nil {
+		return total, 0, nil
+	}
+	freePages, err := unix.SysctlUint32("vm.page_free_count")
+	if err != nil {
+		return total, 0, nil
+	}
+	purgeablePages, err := unix.SysctlUint32("vm.page_purgeable_count")
+	if err != nil {
+		purgeablePages = 0
+	}
+
+	return total, (uint64(freePages) + uint64(purgeablePages)) * uint64(pageSize), nil
+}
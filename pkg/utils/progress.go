@@ -0,0 +1,170 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package utils
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// Renders the progress of sequential phases of a long running process.
+// When interactive, the current phase is rendered on a single line with
+// a spinner and its elapsed time. Otherwise, (for pipes, files and CI logs)
+// each phase start and completion is printed as a plain line instead
+type PhaseProgress struct {
+	writer      io.Writer
+	interactive bool
+
+	startTime      time.Time
+	phase          string
+	phaseStartTime time.Time
+	frame          int
+	rendered       bool
+
+	ticker  *time.Ticker
+	done    chan bool
+	stopped bool
+	lock    sync.Mutex
+}
+
+func NewPhaseProgress(writer io.Writer, interactive bool) *PhaseProgress {
+	progress := &PhaseProgress{
+		writer:      writer,
+		interactive: interactive,
+		startTime:   time.Now(),
+		done:        make(chan bool),
+	}
+
+	if interactive {
+		progress.ticker = time.NewTicker(150 * time.Millisecond)
+		go func() {
+			for {
+				select {
+				case <-progress.done:
+					return
+				case <-progress.ticker.C:
+					progress.lock.Lock()
+					progress.render()
+					progress.lock.Unlock()
+				}
+			}
+		}()
+	}
+
+	return progress
+}
+
+// Completes the current phase (if any) and starts the next one
+func (p *PhaseProgress) StartPhase(phase string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.stopped || phase == p.phase {
+		return
+	}
+
+	p.completePhase()
+	p.phase = phase
+	p.phaseStartTime = time.Now()
+
+	if p.interactive {
+		p.render()
+	} else {
+		fmt.Fprintf(p.writer, "> %s..\n", phase)
+	}
+}
+
+// Prints a line without breaking the rendered progress
+func (p *PhaseProgress) Print(line string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.clear()
+	fmt.Fprint(p.writer, line)
+	if p.interactive && !p.stopped {
+		p.render()
+	}
+}
+
+// Completes the current phase and stops rendering
+// Prints the total time taken when any phase was started
+func (p *PhaseProgress) Stop() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.stopped {
+		return
+	}
+
+	p.completePhase()
+	p.stopped = true
+	if p.interactive {
+		p.ticker.Stop()
+		close(p.done)
+	}
+
+	if !p.phaseStartTime.IsZero() {
+		fmt.Fprintf(p.writer, "> Total time taken: %s\n", FormatDuration(time.Since(p.startTime)))
+	}
+}
+
+// callers must hold the lock
+func (p *PhaseProgress) completePhase() {
+	if p.phase == "" {
+		return
+	}
+
+	p.clear()
+	if p.interactive {
+		fmt.Fprintf(p.writer, "✔ %s (%s)\n", p.phase, FormatDuration(time.Since(p.phaseStartTime)))
+	} else {
+		fmt.Fprintf(p.writer, "> %s completed in %s\n", p.phase, FormatDuration(time.Since(p.phaseStartTime)))
+	}
+	p.phase = ""
+}
+
+// callers must hold the lock
+func (p *PhaseProgress) render() {
+	if !p.interactive || p.stopped || p.phase == "" {
+		return
+	}
+
+	p.frame = (p.frame + 1) % len(spinnerFrames)
+	fmt.Fprintf(p.writer, "\r\033[2K%s %s (%s)", spinnerFrames[p.frame], p.phase, FormatDuration(time.Since(p.phaseStartTime)))
+	p.rendered = true
+}
+
+// callers must hold the lock
+func (p *PhaseProgress) clear() {
+	if p.rendered {
+		fmt.Fprint(p.writer, "\r\033[2K")
+		p.rendered = false
+	}
+}
+
+// Formats the duration rounded to seconds: 1h2m3s, 2m3s, 3s
+func FormatDuration(duration time.Duration) string {
+	return duration.Round(time.Second).String()
+}
@@ -0,0 +1,53 @@
+/**
+ * This file is part of Privado OSS.
+ *
+ * Privado is an open source static code analysis tool to discover data flows in the code.
+ * Copyright (C) 2022 Privado, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ * For more information, contact support@privado.ai
+ */
+
+package utils
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+)
+
+func TestPhaseProgressNonInteractive(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	progress := NewPhaseProgress(buffer, false)
+	progress.StartPhase("Building code property graph")
+	progress.Print("engine output\n")
+	progress.StartPhase("Writing report")
+	progress.Stop()
+	progress.StartPhase("After stop")
+
+	want := regexp.MustCompile(`^> Building code property graph\.\.
+engine output
+> Building code property graph completed in \w+
+> Writing report\.\.
+> Writing report completed in \w+
+> Total time taken: \w+
+$`)
+	if !want.MatchString(buffer.String()) {
+		t.Errorf("got output:\n%s", buffer.String())
+	}
+	if bytes.Contains(buffer.Bytes(), []byte("\033")) {
+		t.Errorf("got ANSI escape sequences in non-interactive output")
+	}
+}
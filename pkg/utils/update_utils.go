@@ -29,48 +29,123 @@ import (
 	"io/ioutil"
 	"math"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/Privado-Inc/privado-cli/pkg/config"
 	"github.com/schollz/progressbar/v3"
+	"golang.org/x/mod/semver"
 )
 
 type gitHubReleaseType struct {
-	TagName     string `json:"tag_name"`
-	PublishedAt string `json:"published_at"`
+	TagName     string                   `json:"tag_name"`
+	PublishedAt string                   `json:"published_at"`
+	Prerelease  bool                     `json:"prerelease"`
+	Draft       bool                     `json:"draft"`
+	Assets      []gitHubReleaseAssetType `json:"assets"`
 }
 
-func GetLatestReleaseFromGitHub(repoName string) (*gitHubReleaseType, error) {
-	endpoint := strings.Replace(config.ExtConfig.GitHubReleasesEndpoint, "${REPO_NAME}", config.AppConfig.PrivadoRepositoryName, 1)
-	url := fmt.Sprintf("%s%s", config.ExtConfig.GitHubAPIHost, endpoint)
+type gitHubReleaseAssetType struct {
+	Name string `json:"name"`
+}
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
+// Returns whether the release has an asset with the filename
+func (r *gitHubReleaseType) HasAsset(filename string) bool {
+	for _, asset := range r.Assets {
+		if asset.Name == filename {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the latest release for the channel (nil when not available):
+// the latest release for stable, otherwise the release with the highest
+// version in the releases list, including prereleases (beta), and nightly
+// builds (nightly)
+func GetLatestReleaseFromGitHub(repoName, channel string) (*gitHubReleaseType, error) {
+	if channel == config.ChannelStable {
+		endpoint := strings.Replace(config.ExtConfig.GitHubReleasesEndpoint, "${REPO_NAME}", repoName, 1)
+		releaseResponse := gitHubReleaseType{}
+		if found, err := getFromGitHubAPI(fmt.Sprintf("%s%s", config.ExtConfig.GitHubAPIHost, endpoint), &releaseResponse); !found || err != nil {
+			return nil, err
+		}
+		return &releaseResponse, nil
+	}
+
+	endpoint := strings.Replace(config.ExtConfig.GitHubReleasesListEndpoint, "${REPO_NAME}", repoName, 1)
+	releasesResponse := []gitHubReleaseType{}
+	if found, err := getFromGitHubAPI(fmt.Sprintf("%s%s", config.ExtConfig.GitHubAPIHost, endpoint), &releasesResponse); !found || err != nil {
 		return nil, err
 	}
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
 
-	defaultClient := &http.Client{}
-	response, err := defaultClient.Do(req)
+	var latestRelease *gitHubReleaseType
+	for i, release := range releasesResponse {
+		if release.Draft || !semver.IsValid(release.TagName) || !isReleaseInChannel(release, channel) {
+			continue
+		}
+		if latestRelease == nil || semver.Compare(release.TagName, latestRelease.TagName) > 0 {
+			latestRelease = &releasesResponse[i]
+		}
+	}
+	return latestRelease, nil
+}
+
+// nightly builds are prereleases, tagged with a "nightly" prerelease version (e.g. v1.2.0-nightly.20221018)
+func isReleaseInChannel(release gitHubReleaseType, channel string) bool {
+	isNightly := strings.HasPrefix(semver.Prerelease(release.TagName), "-nightly")
+	switch channel {
+	case config.ChannelNightly:
+		return true
+	case config.ChannelBeta:
+		return !isNightly
+	default:
+		return !release.Prerelease && !isNightly
+	}
+}
 
-	if err != nil || response.StatusCode != 200 {
+// returns the release for the tag (nil when the release does not exist)
+func GetReleaseByTagFromGitHub(repoName, tag string) (*gitHubReleaseType, error) {
+	replacer := strings.NewReplacer(
+		"${REPO_NAME}", repoName,
+		"${REPO_TAG}", url.PathEscape(tag),
+	)
+	releaseResponse := gitHubReleaseType{}
+	if found, err := getFromGitHubAPI(fmt.Sprintf("%s%s", config.ExtConfig.GitHubAPIHost, replacer.Replace(config.ExtConfig.GitHubReleaseByTagEndpoint)), &releaseResponse); !found || err != nil {
 		return nil, err
 	}
+	return &releaseResponse, nil
+}
 
-	responseData, err := ioutil.ReadAll(response.Body)
+// decodes the response into the value, not found for non-ok statuses
+func getFromGitHubAPI(apiURL string, value interface{}) (found bool, err error) {
+	req, err := http.NewRequest("GET", apiURL, nil)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
 
-	releaseResponse := gitHubReleaseType{}
-	err = json.Unmarshal(responseData, &releaseResponse)
+	response, err := DoHTTPRequest(req, DefaultHTTPRequestOptions)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
+	defer response.Body.Close()
 
-	return &releaseResponse, nil
+	if response.StatusCode != 200 {
+		return false, nil
+	}
+
+	responseData, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return false, err
+	}
+
+	if err := json.Unmarshal(responseData, value); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
 func DownloadToFile(downloadURL, filePath string) error {
@@ -78,12 +153,17 @@ func DownloadToFile(downloadURL, filePath string) error {
 	if err != nil {
 		return err
 	}
-	resp, err := http.DefaultClient.Do(req)
+	// downloads are bounded by a longer timeout, for slow networks
+	resp, err := DoHTTPRequest(req, HTTPRequestOptions{Timeout: 10 * time.Minute, Retries: DefaultHTTPRequestOptions.Retries})
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("received non-ok status for download: %d", resp.StatusCode)
+	}
+
 	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return err
@@ -103,6 +183,45 @@ func DownloadToFile(downloadURL, filePath string) error {
 	return nil
 }
 
+// Downloads small release assets (e.g. checksums, signatures) to memory
+func DownloadToMemory(downloadURL string) ([]byte, error) {
+	req, err := http.NewRequest("GET", downloadURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := DoHTTPRequest(req, DefaultHTTPRequestOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("received non-ok status for download: %d", resp.StatusCode)
+	}
+
+	// the limit guards against unexpected responses
+	return ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// Returns the SHA-256 digest listed for the file in the checksum manifest
+// (sha256sum format: "<digest>  <filename>" per line)
+func GetChecksumFromManifest(manifest []byte, filename string) (string, error) {
+	for _, line := range strings.Split(string(manifest), "\n") {
+		fields := strings.Fields(line)
+		// binary mode entries are prefixed with '*'
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == filename {
+			checksum := strings.ToLower(fields[0])
+			if len(checksum) != 64 {
+				return "", fmt.Errorf("invalid SHA-256 checksum for %s in checksum manifest", filename)
+			}
+			return checksum, nil
+		}
+	}
+
+	return "", fmt.Errorf("no checksum for %s in checksum manifest", filename)
+}
+
 func GetDaysSinceRFC3339String(date string) (int, error) {
 	parsedDate, err := time.Parse(time.RFC3339, date)
 	if err != nil {
@@ -24,6 +24,7 @@ package utils
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -35,9 +36,44 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/schollz/progressbar/v3"
+	"github.com/Privado-Inc/privado-cli/pkg/output"
 )
 
+// Returns the reason a browser cannot be opened in this environment:
+// a remote (SSH) session or no graphical display. Empty if none is identified
+func GetHeadlessEnvironmentReason() string {
+	for _, sshEnv := range []string{"SSH_CONNECTION", "SSH_CLIENT", "SSH_TTY"} {
+		if os.Getenv(sshEnv) != "" {
+			return "SSH session"
+		}
+	}
+
+	// macOS and windows always have a display for an interactive user
+	if runtime.GOOS != "darwin" && runtime.GOOS != "windows" {
+		if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+			return "no display (DISPLAY, WAYLAND_DISPLAY)"
+		}
+	}
+
+	return ""
+}
+
+// Checks if the URL is reachable: any HTTP response (including
+// errors from the server) is considered reachable. Returns the status code
+func CheckURLReachability(url string, timeout time.Duration) (int, error) {
+	req, err := http.NewRequest("HEAD", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	response, err := DoHTTPRequest(req, HTTPRequestOptions{Timeout: timeout})
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	return response.StatusCode, nil
+}
+
 func OpenURLInBrowser(url string) error {
 	var cmd *exec.Cmd
 	errMsg := ""
@@ -64,8 +100,8 @@ func OpenURLInBrowser(url string) error {
 
 	// in case we cannot automatically open due to
 	// unknown OS or an error, print
-	fmt.Println("\n> Unable to open browser")
-	fmt.Println("> Kindly open the following URL to continue:", url)
+	output.Println("\n> Unable to open browser")
+	output.Println("> Kindly open the following URL to continue:", url)
 	return fmt.Errorf(errMsg)
 }
 
@@ -79,9 +115,16 @@ func WaitForResponsiveURL(url string, intervalSeconds int) {
 	defer ticker.Stop()
 
 	for range ticker.C {
-		res, err := http.Head(url)
-		if err == nil && res.StatusCode == 200 {
-			return
+		req, err := http.NewRequest("HEAD", url, nil)
+		if err != nil {
+			continue
+		}
+		res, err := DoHTTPRequest(req, HTTPRequestOptions{Timeout: time.Duration(intervalSeconds) * time.Second})
+		if err == nil {
+			res.Body.Close()
+			if res.StatusCode == 200 {
+				return
+			}
 		}
 	}
 }
@@ -92,67 +135,48 @@ func WaitAndOpenURL(url string, sgn chan bool, interval int) {
 	OpenURLInBrowser(url)
 }
 
-func RunOnCtrlC(cleanupFn func()) chan os.Signal {
-	notifySignal := make(chan os.Signal)
+// Listens for interrupt and termination signals (two-staged)
+// the first signal calls interruptFn to allow a graceful shutdown,
+// any subsequent signal calls forceFn. Both are called in new goroutines,
+// so a blocking shutdown does not prevent receiving further signals
+func RunOnCtrlC(interruptFn, forceFn func(sgn os.Signal)) chan os.Signal {
+	notifySignal := make(chan os.Signal, 2)
 	signal.Notify(notifySignal, os.Interrupt, syscall.SIGTERM)
 	go func() {
-		<-notifySignal
-		cleanupFn()
-		os.Exit(0)
+		receivedSignals := 0
+		for sgn := range notifySignal {
+			receivedSignals++
+			if receivedSignals == 1 {
+				go interruptFn(sgn)
+			} else {
+				go forceFn(sgn)
+			}
+		}
 	}()
 
 	return notifySignal
 }
 
+// stops listening on the channel returned by RunOnCtrlC
 func ClearSignals(sgn chan os.Signal) {
 	signal.Stop(sgn)
+	close(sgn)
 }
 
-func RenderProgressSpinnerWithMessages(complete, quit chan bool, loadMessages, afterLoadMessages []string) {
-	if len(loadMessages) == 0 {
-		// default message
-		loadMessages = []string{"Loading.."}
+// Returns the conventional exit status (128 + signal number)
+// for a process terminated by the signal
+func GetExitStatusForSignal(sgn os.Signal) int {
+	if sgn == syscall.SIGTERM {
+		return 143
 	}
+	return 130
+}
 
-	messageIndex := 0
-	messageRotationTicker := time.NewTicker(20 * time.Second)
-
-	bar := progressbar.NewOptions(-1,
-		progressbar.OptionFullWidth(),
-		// Good spinners: 0, 31, 51, 52, 54
-		progressbar.OptionSpinnerType(52),
-		progressbar.OptionEnableColorCodes(true),
-		progressbar.OptionSetDescription(loadMessages[messageIndex]),
-		progressbar.OptionShowCount(),
-	)
-
-	for {
-		seconds := bar.State().SecondsSince
-		select {
-		case <-quit:
-			fmt.Println()
-			bar.Close()
-		case <-complete:
-			bar.Close()
-			fmt.Println()
-			fmt.Println("> Complete")
-			fmt.Println("> Total Time taken:", seconds, "seconds")
-
-			if len(afterLoadMessages) > 0 {
-				for _, message := range afterLoadMessages {
-					fmt.Print("\n> ", message)
-				}
-			}
-			fmt.Println()
-			return
-		case <-messageRotationTicker.C:
-			messageIndex++
-			bar.Describe(loadMessages[messageIndex%len(loadMessages)])
-		default:
-			bar.Set(int(seconds))
-			time.Sleep(150 * time.Millisecond)
-		}
-	}
+// matches ANSI escape sequences: CSI (colors, cursor movement) and OSC (titles, links)
+var ansiEscapeSequenceRegex = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+func StripANSIEscapeSequences(str string) string {
+	return ansiEscapeSequenceRegex.ReplaceAllString(str, "")
 }
 
 func ExtractURLFromString(str string) string {
@@ -166,6 +190,10 @@ func ExtractURLFromString(str string) string {
 }
 
 func ShowConfirmationPrompt(msg string) (bool, error) {
+	if !output.IsInteractive() {
+		return false, errors.New("cannot prompt in non-interactive mode")
+	}
+
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Printf("%s (y/N): ", msg)
 	ans, err := reader.ReadString('\n')